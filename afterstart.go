@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// InvokeAfterStart registers functions that run after the application has
+// started, once all OnStart hooks have succeeded. This is useful for setup
+// that requires the application to be running, like registering with
+// service discovery.
+//
+//  fx.InvokeAfterStart(func(r *Registry, s *Server) error {
+//    return r.Register(s.Addr())
+//  })
+//
+// Like functions passed to fx.Invoke, the functions are resolved during
+// New: their arguments are built in the order of all invocations, and New
+// fails if they can't be. The functions themselves are called by Start,
+// in order, every time the application starts. If a function returns an
+// error, Start rolls back the hooks that were started and fails with an
+// InvokeError.
+func InvokeAfterStart(funcs ...interface{}) Option {
+	return invokeAfterStartOption{
+		Targets: funcs,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
+}
+
+type invokeAfterStartOption struct {
+	Targets []interface{}
+	Stack   fxreflect.Stack
+}
+
+func (o invokeAfterStartOption) apply(app *App) {
+	for _, target := range o.Targets {
+		app.invokes = append(app.invokes, invoke{
+			Target:     target,
+			Stack:      o.Stack,
+			AfterStart: true,
+		})
+	}
+}
+
+func (o invokeAfterStartOption) visit(v *optionVisitor) {
+	v.report(o, "fx.InvokeAfterStart", o.Stack, o.Targets...)
+}
+
+func (o invokeAfterStartOption) String() string {
+	items := make([]string, len(o.Targets))
+	for i, f := range o.Targets {
+		items[i] = fxreflect.FuncName(f)
+	}
+	return fmt.Sprintf("fx.InvokeAfterStart(%s)", strings.Join(items, ", "))
+}
+
+// afterStart is a function passed to fx.InvokeAfterStart along with the
+// arguments it was resolved with.
+type afterStart struct {
+	Function interface{}
+	Stack    fxreflect.Stack
+
+	args []reflect.Value
+}
+
+// capture returns a function with the same signature as the function to
+// run. Invoking it records its arguments rather than running the function.
+func (a *afterStart) capture() interface{} {
+	ft := reflect.TypeOf(a.Function)
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		a.args = args
+		results := make([]reflect.Value, ft.NumOut())
+		for i := range results {
+			results[i] = reflect.Zero(ft.Out(i))
+		}
+		return results
+	}).Interface()
+}
+
+// runAfterStart calls the functions passed to fx.InvokeAfterStart in
+// order, returning the first error.
+func (app *App) runAfterStart() error {
+	for _, a := range app.afterStart {
+		app.log.LogEvent(&fxevent.InvokeAfterStart{Function: a.Function})
+
+		fn := a.Function
+		if app.recoverFromPanics {
+			fn = recoverFunc(a.Function, a.Stack, fn)
+		}
+
+		var results []reflect.Value
+		if reflect.TypeOf(fn).IsVariadic() {
+			results = reflect.ValueOf(fn).CallSlice(a.args)
+		} else {
+			results = reflect.ValueOf(fn).Call(a.args)
+		}
+
+		if len(results) == 0 || results[len(results)-1].Type() != _typeOfError {
+			continue
+		}
+		if err, _ := results[len(results)-1].Interface().(error); err != nil {
+			app.log.LogEvent(&fxevent.InvokeAfterStartError{
+				Function:   a.Function,
+				Err:        err,
+				Stacktrace: fmt.Sprintf("%+v", a.Stack), // format stack trace as multi-line
+			})
+			return &InvokeError{
+				Function: a.Function,
+				Stack:    a.Stack,
+				Err:      err,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+)
+
+func TestInvokeAfterStart(t *testing.T) {
+	type A struct{}
+
+	t.Run("RunsAfterHooks", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.Provide(func(lc fx.Lifecycle) *A {
+				calls = append(calls, "constructor")
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error {
+						calls = append(calls, "start")
+						return nil
+					},
+				})
+				return &A{}
+			}),
+			fx.InvokeAfterStart(func(*A) {
+				calls = append(calls, "after start")
+			}),
+		)
+		assert.Equal(t, []string{"constructor"}, calls,
+			"arguments must be resolved, but the function not called, by New")
+
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"constructor", "start", "after start"}, calls)
+	})
+
+	t.Run("Order", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.InvokeAfterStart(func() { calls = append(calls, "1") }),
+			fx.Invoke(func() { calls = append(calls, "invoke") }),
+			fx.InvokeAfterStart(
+				func() { calls = append(calls, "2") },
+				func() { calls = append(calls, "3") },
+			),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"invoke", "1", "2", "3"}, calls)
+	})
+
+	t.Run("ErrorRollsBack", func(t *testing.T) {
+		var calls []string
+		spy := new(fxlog.Spy)
+		app := fx.New(
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnStop: func(context.Context) error {
+						calls = append(calls, "stop")
+						return nil
+					},
+				})
+			}),
+			fx.InvokeAfterStart(func() error {
+				return errors.New("great sadness")
+			}),
+			fx.InvokeAfterStart(func() { calls = append(calls, "not called") }),
+		)
+		require.NoError(t, app.Err())
+
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError, got %v", err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Equal(t, []string{"stop"}, calls)
+
+		assert.Equal(t, []string{
+			"InvokeAfterStart",
+			"InvokeAfterStartError",
+			"Rollback",
+			"LifecycleHookExecuting",
+			"LifecycleHookExecuted",
+		}, spy.EventTypes()[len(spy.EventTypes())-5:])
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		var called bool
+		app := NewForTest(t,
+			fx.InvokeAfterStart(func(*A) { called = true }),
+		)
+
+		err := app.Err()
+		require.Error(t, err)
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError, got %v", err)
+		assert.Contains(t, err.Error(), "missing type: *fx_test.A")
+		assert.False(t, called)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.RecoverFromPanics(),
+			fx.InvokeAfterStart(func() { panic("great sadness") }),
+		)
+		require.NoError(t, app.Err())
+
+		err := app.Start(context.Background())
+		var panicErr *fx.PanicError
+		require.True(t, errors.As(err, &panicErr), "expected a PanicError, got %v", err)
+		assert.Equal(t, "great sadness", panicErr.Value)
+	})
+}
@@ -22,8 +22,10 @@ package fx
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
+	"go.uber.org/dig"
 	"go.uber.org/fx/internal/fxreflect"
 )
 
@@ -59,19 +61,64 @@ type Annotated struct {
 	// by the constructor. For more information on named values, see the documentation
 	// for the fx.Out type.
 	//
-	// A name option may not be provided if a group option is provided.
+	// If a group option is provided as well, the values are available both
+	// by name and in the group. The constructor is still called only once.
 	Name string
 
 	// If specified, this will be used as the group name for all non-error values returned
 	// by the constructor. For more information on value groups, see the package documentation.
 	//
-	// A group option may not be provided if a name option is provided.
+	// A group option may be provided along with a name option. See Name.
 	//
 	// Similar to group tags, the group name may be followed by a `,flatten`
 	// option to indicate that each element in the slice returned by the
 	// constructor should be injected into the value group individually.
 	Group string
 
+	// If non-zero, positions the values returned by the constructor in
+	// their value group. Consumers of the group receive its values sorted
+	// by ascending order. Values with the same order keep the order in
+	// which they were provided. Values without an order are placed at
+	// order 0, in no particular order.
+	//
+	// Result structs may give an order to their value group fields with
+	// the order tag.
+	//
+	//   type result struct {
+	//     fx.Out
+	//
+	//     Handler Middleware `group:"middleware" order:"-10"`
+	//   }
+	//
+	// An order may only be provided along with a group. If a name is
+	// provided as well, the order only applies to the values in the group.
+	Order int
+
+	// If specified, identifies the values returned by the constructor in
+	// their value group. Besides slices, consumers may request the values
+	// of a group given a key as a map from keys to values:
+	//
+	//   type params struct {
+	//     fx.In
+	//
+	//     Handlers map[string]http.Handler `group:"handlers"`
+	//   }
+	//
+	// Values without a key are left out of such maps. Keys must be unique
+	// among the values of the same type in a group.
+	//
+	// Result structs may give a key to their value group fields with the key
+	// tag. A key may only be provided along with a group, and cannot be used
+	// with the flatten option.
+	Key string
+
+	// If true, the constructor will be called when the application is
+	// built, even if none of its results are used. See fx.Eager for
+	// details.
+	//
+	// Eager has no effect when used with fx.Supply.
+	Eager bool
+
 	// Target is the constructor or value being annotated with fx.Annotated.
 	Target interface{}
 }
@@ -84,8 +131,60 @@ func (a Annotated) String() string {
 	if len(a.Group) > 0 {
 		fields = append(fields, fmt.Sprintf("Group: %q", a.Group))
 	}
+	if a.Order != 0 {
+		fields = append(fields, fmt.Sprintf("Order: %d", a.Order))
+	}
+	if len(a.Key) > 0 {
+		fields = append(fields, fmt.Sprintf("Key: %q", a.Key))
+	}
+	if a.Eager {
+		fields = append(fields, "Eager: true")
+	}
 	if a.Target != nil {
 		fields = append(fields, fmt.Sprintf("Target: %v", fxreflect.FuncName(a.Target)))
 	}
 	return fmt.Sprintf("fx.Annotated{%v}", strings.Join(fields, ", "))
 }
+
+// provideToGroup adds the values that the constructor target of ann
+// produces with the name of ann to the group of ann, so that values which
+// have both a name and a group are built once.
+func (app *App) provideToGroup(target interface{}, ann Annotated) error {
+	ft := reflect.TypeOf(target)
+	fields := []reflect.StructField{{
+		Name:      "In",
+		Type:      reflect.TypeOf(In{}),
+		Anonymous: true,
+	}}
+	var types []reflect.Type
+	for i := 0; i < ft.NumOut(); i++ {
+		if t := ft.Out(i); t != _typeOfError {
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("Field%d", len(types)),
+				Type: t,
+				Tag:  reflect.StructTag(fmt.Sprintf(`name:"%v"`, ann.Name)),
+			})
+			types = append(types, t)
+		}
+	}
+
+	fnType := reflect.FuncOf([]reflect.Type{reflect.StructOf(fields)}, types, false /* variadic */)
+	var fn interface{} = reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, len(types))
+		for i := range results {
+			results[i] = args[0].Field(i + 1)
+		}
+		return results
+	}).Interface()
+
+	opts := []dig.ProvideOption{dig.Group(ann.Group)}
+	if ann.Order != 0 || len(ann.Key) > 0 {
+		var err error
+		if fn, err = app.groupResults(fn, &ann); err != nil {
+			return err
+		}
+		name, _ := splitGroup(ann.Group)
+		opts[0] = dig.Group(hiddenGroup(name) + ",flatten")
+	}
+	return app.container.Provide(fn, opts...)
+}
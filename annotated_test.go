@@ -21,6 +21,7 @@
 package fx_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,8 @@ import (
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/internal/fxreflect"
 )
 
 func TestAnnotated(t *testing.T) {
@@ -57,6 +60,128 @@ func TestAnnotated(t *testing.T) {
 		assert.NotNil(t, in.A, "expected in.A to be injected")
 		assert.Equal(t, "foo", in.A.name, "expected to get a type 'a' of name 'foo'")
 	})
+
+	t.Run("NameAndGroup", func(t *testing.T) {
+		type result struct {
+			fx.In
+
+			A  *a   `name:"foo"`
+			As []*a `group:"bar"`
+		}
+
+		var calls int
+		var got result
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Name:  "foo",
+					Group: "bar",
+					Target: func() *a {
+						calls++
+						return &a{name: "foo"}
+					},
+				},
+				fx.Annotated{
+					Group:  "bar",
+					Target: func() *a { return &a{name: "baz"} },
+				},
+			),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, 1, calls, "constructor must be called once")
+		require.NotNil(t, got.A)
+		assert.Equal(t, "foo", got.A.name)
+		assert.Len(t, got.As, 2)
+		assert.Contains(t, got.As, got.A, "named value must be in the group")
+	})
+
+	t.Run("NameAndFlattenedGroup", func(t *testing.T) {
+		type result struct {
+			fx.In
+
+			Names []string `name:"foo"`
+			All   []string `group:"bar"`
+		}
+
+		var got result
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Name:   "foo",
+					Group:  "bar,flatten",
+					Target: func() []string { return []string{"x", "y"} },
+				},
+			),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"x", "y"}, got.Names)
+		assert.ElementsMatch(t, []string{"x", "y"}, got.All)
+	})
+
+	t.Run("NameAndOrderedGroup", func(t *testing.T) {
+		type result struct {
+			fx.In
+
+			First string            `name:"first"`
+			All   []string          `group:"bar"`
+			Keyed map[string]string `group:"bar"`
+		}
+
+		var got result
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Group:  "bar",
+					Key:    "second",
+					Order:  1,
+					Target: func() string { return "b" },
+				},
+				fx.Annotated{
+					Name:   "first",
+					Group:  "bar",
+					Key:    "first",
+					Order:  -1,
+					Target: func() string { return "a" },
+				},
+			),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, "a", got.First)
+		assert.Equal(t, []string{"a", "b"}, got.All)
+		assert.Equal(t, map[string]string{"first": "a", "second": "b"}, got.Keyed)
+	})
+
+	t.Run("NameAndGroupEvent", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Provide(
+				fx.Annotated{
+					Name:   "foo",
+					Group:  "bar",
+					Target: newA,
+				},
+			),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var outputs []string
+		for _, e := range spy.Events() {
+			if p, ok := e.(*fxevent.Provide); ok && len(p.OutputTypeNames) == 2 {
+				outputs = p.OutputTypeNames
+			}
+		}
+		assert.Equal(t, []string{
+			`*fx_test.a[name = "foo"]`,
+			`*fx_test.a[group = "bar"]`,
+		}, outputs)
+	})
 }
 
 func TestAnnotatedWrongUsage(t *testing.T) {
@@ -102,6 +227,11 @@ func TestAnnotatedWrongUsage(t *testing.T) {
 		assert.Contains(t, err.Error(), "fx.Provide received go.uber.org/fx_test.TestAnnotatedWrongUsage")
 		assert.Contains(t, err.Error(), "go.uber.org/fx_test.TestAnnotatedWrongUsage")
 		assert.Contains(t, err.Error(), "/fx/annotated_test.go")
+
+		var provideErr *fx.ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+		assert.Contains(t, fxreflect.FuncName(provideErr.Constructor), "TestAnnotatedWrongUsage")
+		assert.Contains(t, provideErr.Stack.String(), "/fx/annotated_test.go")
 	})
 
 	t.Run("Result Type", func(t *testing.T) {
@@ -148,6 +278,21 @@ func TestAnnotatedString(t *testing.T) {
 			give: fx.Annotated{Name: "foo", Group: "bar"},
 			want: `fx.Annotated{Name: "foo", Group: "bar"}`,
 		},
+		{
+			desc: "order",
+			give: fx.Annotated{Group: "foo", Order: -10},
+			want: `fx.Annotated{Group: "foo", Order: -10}`,
+		},
+		{
+			desc: "key",
+			give: fx.Annotated{Group: "foo", Key: "bar"},
+			want: `fx.Annotated{Group: "foo", Key: "bar"}`,
+		},
+		{
+			desc: "eager",
+			give: fx.Annotated{Name: "foo", Eager: true},
+			want: `fx.Annotated{Name: "foo", Eager: true}`,
+		},
 		{
 			desc: "target",
 			give: fx.Annotated{Target: func() {}},
@@ -32,11 +32,13 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unsafe"
 
 	"go.uber.org/dig"
 	"go.uber.org/fx/fxevent"
 	"go.uber.org/fx/internal/fxlog"
 	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
 	"go.uber.org/fx/internal/lifecycle"
 	"go.uber.org/multierr"
 )
@@ -53,6 +55,7 @@ type Option interface {
 	fmt.Stringer
 
 	apply(*App)
+	visit(*optionVisitor)
 }
 
 // Provide registers any number of constructor functions, teaching the
@@ -78,6 +81,12 @@ type Option interface {
 // these properties make it perfectly reasonable to Provide a large number of
 // constructors even if only a fraction of them are used.
 //
+// The same constructor may be provided more than once, as when two libraries
+// include the same module. It's provided only once, unless it's annotated
+// differently each time with fx.Annotated. Closures built by the same
+// function literal are different constructors if they capture different
+// variables.
+//
 // See the documentation of the In and Out types for advanced features,
 // including optional parameters and named instances.
 //
@@ -102,10 +111,16 @@ func (o provideOption) apply(app *App) {
 		app.provides = append(app.provides, provide{
 			Target: target,
 			Stack:  o.Stack,
+			Key:    newProvideKey(target),
+			Source: app.source(o.Stack),
 		})
 	}
 }
 
+func (o provideOption) visit(v *optionVisitor) {
+	v.report(o, "fx.Provide", o.Stack, o.Targets...)
+}
+
 func (o provideOption) String() string {
 	items := make([]string, len(o.Targets))
 	for i, c := range o.Targets {
@@ -153,6 +168,10 @@ func (o invokeOption) apply(app *App) {
 	}
 }
 
+func (o invokeOption) visit(v *optionVisitor) {
+	v.report(o, "fx.Invoke", o.Stack, o.Targets...)
+}
+
 func (o invokeOption) String() string {
 	items := make([]string, len(o.Targets))
 	for i, f := range o.Targets {
@@ -168,17 +187,31 @@ func (o invokeOption) String() string {
 // Similar to invocations, errors are applied in order. All Provide and Invoke
 // options registered before or after an Error option will not be applied.
 func Error(errs ...error) Option {
-	return errorOption(errs)
+	return errorOption{
+		Errors: errs,
+		Stack:  fxreflect.CallerStack(1, 0),
+	}
 }
 
-type errorOption []error
+type errorOption struct {
+	Errors []error
+	Stack  fxreflect.Stack
+}
+
+func (o errorOption) apply(app *App) {
+	app.err = multierr.Append(app.err, multierr.Combine(o.Errors...))
+}
 
-func (errs errorOption) apply(app *App) {
-	app.err = multierr.Append(app.err, multierr.Combine(errs...))
+func (o errorOption) visit(v *optionVisitor) {
+	targets := make([]interface{}, len(o.Errors))
+	for i, err := range o.Errors {
+		targets[i] = err
+	}
+	v.report(o, "fx.Error", o.Stack, targets...)
 }
 
-func (errs errorOption) String() string {
-	return fmt.Sprintf("fx.Error(%v)", multierr.Combine(errs...))
+func (o errorOption) String() string {
+	return fmt.Sprintf("fx.Error(%v)", multierr.Combine(o.Errors...))
 }
 
 // Options converts a collection of Options into a single Option. This allows
@@ -213,20 +246,37 @@ func (errs errorOption) String() string {
 // Use this pattern sparingly, since it limits the user's ability to customize
 // their application.
 func Options(opts ...Option) Option {
-	return optionGroup(opts)
+	return optionGroup{
+		Options: opts,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
 }
 
-type optionGroup []Option
+type optionGroup struct {
+	Options []Option
+	Stack   fxreflect.Stack
+}
 
 func (og optionGroup) apply(app *App) {
-	for _, opt := range og {
+	if f, ok := og.Stack.Caller(); ok {
+		including := app.including
+		app.including = append(fxreflect.Stack{f}, including...)
+		defer func() { app.including = including }()
+	}
+
+	for _, opt := range og.Options {
 		opt.apply(app)
 	}
 }
 
+func (og optionGroup) visit(v *optionVisitor) {
+	v.report(og, "fx.Options", og.Stack)
+	v.nest(og, og.Options...)
+}
+
 func (og optionGroup) String() string {
-	items := make([]string, len(og))
-	for i, opt := range og {
+	items := make([]string, len(og.Options))
+	for i, opt := range og.Options {
 		items[i] = fmt.Sprint(opt)
 	}
 	return fmt.Sprintf("fx.Options(%s)", strings.Join(items, ", "))
@@ -234,32 +284,52 @@ func (og optionGroup) String() string {
 
 // StartTimeout changes the application's start timeout.
 func StartTimeout(v time.Duration) Option {
-	return startTimeoutOption(v)
+	return startTimeoutOption{
+		Timeout: v,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
 }
 
-type startTimeoutOption time.Duration
+type startTimeoutOption struct {
+	Timeout time.Duration
+	Stack   fxreflect.Stack
+}
 
 func (t startTimeoutOption) apply(app *App) {
-	app.startTimeout = time.Duration(t)
+	app.startTimeout = t.Timeout
+}
+
+func (t startTimeoutOption) visit(v *optionVisitor) {
+	v.report(t, "fx.StartTimeout", t.Stack, t.Timeout)
 }
 
 func (t startTimeoutOption) String() string {
-	return fmt.Sprintf("fx.StartTimeout(%v)", time.Duration(t))
+	return fmt.Sprintf("fx.StartTimeout(%v)", t.Timeout)
 }
 
 // StopTimeout changes the application's stop timeout.
 func StopTimeout(v time.Duration) Option {
-	return stopTimeoutOption(v)
+	return stopTimeoutOption{
+		Timeout: v,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
 }
 
-type stopTimeoutOption time.Duration
+type stopTimeoutOption struct {
+	Timeout time.Duration
+	Stack   fxreflect.Stack
+}
 
 func (t stopTimeoutOption) apply(app *App) {
-	app.stopTimeout = time.Duration(t)
+	app.stopTimeout = t.Timeout
+}
+
+func (t stopTimeoutOption) visit(v *optionVisitor) {
+	v.report(t, "fx.StopTimeout", t.Stack, t.Timeout)
 }
 
 func (t stopTimeoutOption) String() string {
-	return fmt.Sprintf("fx.StopTimeout(%v)", time.Duration(t))
+	return fmt.Sprintf("fx.StopTimeout(%v)", t.Timeout)
 }
 
 // WithLogger specifies how Fx should build an fxevent.Logger to log its events
@@ -294,6 +364,10 @@ func (l withLoggerOption) apply(app *App) {
 	}
 }
 
+func (l withLoggerOption) visit(v *optionVisitor) {
+	v.report(l, "fx.WithLogger", l.Stack, l.constructor)
+}
+
 func (l withLoggerOption) String() string {
 	return fmt.Sprintf("fx.WithLogger(%s)", fxreflect.FuncName(l.constructor))
 }
@@ -310,16 +384,26 @@ type Printer interface {
 // Logger redirects the application's log output to the provided printer.
 // Deprecated: use WithLogger instead.
 func Logger(p Printer) Option {
-	return loggerOption{p}
+	return loggerOption{
+		p:     p,
+		Stack: fxreflect.CallerStack(1, 0),
+	}
 }
 
-type loggerOption struct{ p Printer }
+type loggerOption struct {
+	p     Printer
+	Stack fxreflect.Stack
+}
 
 func (l loggerOption) apply(app *App) {
 	np := writerFromPrinter(l.p)
 	app.log = fxlog.DefaultLogger(np) // assuming np is thread-safe.
 }
 
+func (l loggerOption) visit(v *optionVisitor) {
+	v.report(l, "fx.Logger", l.Stack, l.p)
+}
+
 func (l loggerOption) String() string {
 	return fmt.Sprintf("fx.Logger(%v)", l.p)
 }
@@ -369,15 +453,78 @@ type App struct {
 	// Constructors and its dependencies.
 	provides []provide
 	invokes  []invoke
+	defaults []provide
+	// Results of fx.If options that were applied but not logged yet. They're
+	// logged once the logger is set up, or once the fx.When options they're
+	// nested in are applied.
+	ifs []*fxevent.Condition
+	// Functions passed to fx.InvokeAfterStart that were resolved, in the
+	// order they run when the application starts.
+	afterStart []*afterStart
+	// Constructors successfully provided to the container and functions
+	// that will be invoked, along with their dependencies. It's only
+	// changed while options are applied, before any function is invoked,
+	// so it may be read without synchronization once a function could
+	// have received a value from the container.
+	graph *graph.Graph
+	// Sources of constructors successfully provided to the container, used
+	// to ignore duplicates. See provide.Source.
+	provided map[provideKey]fxreflect.Stack
+	// Callers of the fx.Options being applied, innermost first.
+	including fxreflect.Stack
 	// Used to setup logging within fx.
 	log            fxevent.Logger
-	logConstructor *provide // set only if fx.WithLogger was used
+	logConstructor *provide  // set only if fx.WithLogger was used
+	debugger       *Debugger // set only if fx.DebugHandler was used
 	// Timeouts used
 	startTimeout time.Duration
 	stopTimeout  time.Duration
 	// Decides how we react to errors when building the graph.
 	errorHooks []ErrorHandler
 	validate   bool
+	// Whether to report unused constructors, and whether to fail if
+	// there are any.
+	reportUnused bool
+	strict       bool
+	// Whether to call all constructors eagerly, and constructors that
+	// must be called eagerly regardless.
+	eager      bool
+	eagerNodes map[*graph.Node]struct{}
+	// Whether to append lifecycle hooks for the values constructors
+	// produce, and the values hooks were appended for. See AutoLifecycle.
+	autoLifecycle bool
+	autoHooked    map[autoHookKey]struct{}
+	// Whether to recover from panics in constructors, invokes, and hooks.
+	recoverFromPanics bool
+	// Number of constructors that produce values with an order or a key
+	// in their value group, and the constructors of values with a key.
+	numGrouped int
+	groupKeys  map[groupKey]*graph.Node
+	// Options the application was built with. See validateWhen.
+	options []Option
+	// Number of fx.When conditions evaluated so far, and the one whose
+	// guarded options are applied while validating. See validateWhen.
+	numWhens   int
+	assumeWhen int
+	// Error of the first fx.When condition that failed. Unlike errors
+	// providing constructors, it's reported like the errors of invoked
+	// functions.
+	whenErr error
+	// Application this one was built from with NewChild, and the
+	// constructors that provide the values inherited from it.
+	parent    *App
+	inherited map[*graph.Node]bool
+	// Serializes requests made to the container after New. See App.value.
+	containerMu sync.Mutex
+	// Child applications that start after and stop before this one, and
+	// whether this application is running.
+	childrenMu sync.Mutex
+	children   []*App
+	running    bool
+	// Request-scoped constructors, and the Scopes that resolves them. Set
+	// only if fx.RequestScoped was used.
+	scoped []provide
+	scopes *Scopes
 	// Used to signal shutdowns.
 	donesMu sync.RWMutex
 	dones   []chan os.Signal
@@ -391,9 +538,86 @@ type provide struct {
 	// Stack trace of where this provide was made.
 	Stack fxreflect.Stack
 
+	// Key identifies the option that provided Target, if it may be
+	// included more than once.
+	Key provideKey
+
+	// Source is where the option that provided Target was included from:
+	// its caller, followed by the callers of the fx.Options enclosing it.
+	// Duplicates are reported with it.
+	Source fxreflect.Stack
+
+	// Method is the method expression that Target is a method value of, if
+	// Target was provided by fx.ProvideStruct. Target is reported as Method
+	// in events, errors, and graphs.
+	Method interface{}
+
+	// Option is the name of the option Target was passed to, if it isn't
+	// fx.Provide. It's reported in errors.
+	Option string
+
 	// IsSupply is true when the Target constructor was emitted by fx.Supply.
-	IsSupply   bool
-	SupplyType reflect.Type // set only if IsSupply
+	IsSupply bool
+
+	// IsDefault is true when the Target constructor was emitted by
+	// fx.Default.
+	IsDefault bool
+
+	// IsBuiltin is true when the Target constructor was provided by Fx
+	// itself, like the constructor for Lifecycle.
+	IsBuiltin bool
+
+	SupplyType reflect.Type // set only if IsSupply or IsDefault
+}
+
+// provideKey identifies a constructor along with its annotations. The same
+// constructor provided more than once, like by a module included by two
+// libraries, has the same key. Two provides with the same non-zero key are
+// duplicates.
+type provideKey struct {
+	// Closure of the constructor function. See fxreflect.Closure.
+	Func unsafe.Pointer
+
+	// Annotations of the constructor, if it was wrapped in fx.Annotated.
+	Name     string
+	Group    string
+	Order    int
+	GroupKey string
+	Eager    bool
+}
+
+// newProvideKey returns the key of the given constructor, which may be an
+// fx.Annotated. It returns the zero key if the constructor isn't a
+// function.
+func newProvideKey(constructor interface{}) provideKey {
+	var k provideKey
+	if ann, ok := constructor.(Annotated); ok {
+		k = provideKey{
+			Name:     ann.Name,
+			Group:    ann.Group,
+			Order:    ann.Order,
+			GroupKey: ann.Key,
+			Eager:    ann.Eager,
+		}
+		constructor = ann.Target
+	}
+
+	v := reflect.ValueOf(constructor)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return provideKey{}
+	}
+	k.Func = fxreflect.Closure(constructor)
+	return k
+}
+
+// source returns where an option created at the given stack is included
+// from. See provide.Source.
+func (app *App) source(stack fxreflect.Stack) fxreflect.Stack {
+	f, ok := stack.Caller()
+	if !ok {
+		return stack
+	}
+	return append(fxreflect.Stack{f}, app.including...)
 }
 
 // invoke is a single invocation request to Fx.
@@ -403,6 +627,19 @@ type invoke struct {
 
 	// Stack trace of where this invoke was made.
 	Stack fxreflect.Stack
+
+	// Provided is true when Target was also provided as a constructor by
+	// fx.InvokeAndProvide. Invoking it requests its results rather than
+	// calling it again.
+	Provided bool
+
+	// AfterStart is true when Target was passed to fx.InvokeAfterStart.
+	// Invoking it only resolves its arguments; it's called by Start.
+	AfterStart bool
+
+	// Set only for placeholders left by fx.When. These are replaced with
+	// the invokes of the guarded options once the condition is evaluated.
+	When *whenOption
 }
 
 // ErrorHandler handles Fx application startup errors.
@@ -414,18 +651,32 @@ type ErrorHandler interface {
 // They are executed on invoke failures. Passing multiple ErrorHandlers appends
 // the new handlers to the application's existing list.
 func ErrorHook(funcs ...ErrorHandler) Option {
-	return errorHookOption(funcs)
+	return errorHookOption{
+		Handlers: funcs,
+		Stack:    fxreflect.CallerStack(1, 0),
+	}
 }
 
-type errorHookOption []ErrorHandler
+type errorHookOption struct {
+	Handlers []ErrorHandler
+	Stack    fxreflect.Stack
+}
 
 func (eho errorHookOption) apply(app *App) {
-	app.errorHooks = append(app.errorHooks, eho...)
+	app.errorHooks = append(app.errorHooks, eho.Handlers...)
+}
+
+func (eho errorHookOption) visit(v *optionVisitor) {
+	targets := make([]interface{}, len(eho.Handlers))
+	for i, eh := range eho.Handlers {
+		targets[i] = eh
+	}
+	v.report(eho, "fx.ErrorHook", eho.Stack, targets...)
 }
 
 func (eho errorHookOption) String() string {
-	items := make([]string, len(eho))
-	for i, eh := range eho {
+	items := make([]string, len(eho.Handlers))
+	for i, eh := range eho.Handlers {
 		items[i] = fmt.Sprint(eh)
 	}
 	return fmt.Sprintf("fx.ErrorHook(%v)", strings.Join(items, ", "))
@@ -443,25 +694,35 @@ func (ehl errorHandlerList) HandleError(err error) {
 func validate(validate bool) Option {
 	return &validateOption{
 		validate: validate,
+		Stack:    fxreflect.CallerStack(1, 0),
 	}
 }
 
 type validateOption struct {
 	validate bool
+	Stack    fxreflect.Stack
 }
 
 func (o validateOption) apply(app *App) {
 	app.validate = o.validate
 }
 
+func (o validateOption) visit(v *optionVisitor) {
+	v.report(o, "fx.validate", o.Stack, o.validate)
+}
+
 func (o validateOption) String() string {
 	return fmt.Sprintf("fx.validate(%v)", o.validate)
 }
 
 // ValidateApp validates that supplied graph would run and is not missing any dependencies. This
-// method does not invoke actual input functions.
+// method does not invoke actual input functions. See When for how options
+// guarded by conditions are validated.
 func ValidateApp(opts ...Option) error {
-	opts = append(opts, validate(true))
+	opts = append(opts, &validateOption{
+		validate: true,
+		Stack:    fxreflect.CallerStack(1, 0),
+	})
 	app := New(opts...)
 
 	return app.Err()
@@ -479,10 +740,21 @@ func (app *App) constructCustomLogger(buffer *logBuffer) error {
 	// TODO: Use dig.FillProvideInfo to inspect the provided constructor
 	// and fail the application if its signature didn't match.
 
-	return app.container.Invoke(func(log fxevent.Logger) {
+	// The logger is built right away, so its constructor is recorded like
+	// an invoked function rather than a constructor that may go unused.
+	node := &graph.Node{
+		Func:   p.Target,
+		Stack:  p.Stack,
+		Params: fxreflect.InspectSignature(p.Target).Params,
+	}
+	app.graph.AddInvoke(node)
+
+	err := app.container.Invoke(func(log fxevent.Logger) {
+		log = app.withDebugger(log)
 		app.log = log
 		buffer.Connect(log)
 	})
+	return app.containerError(node, err)
 }
 
 // New creates and initializes an App, immediately executing any functions
@@ -507,11 +779,16 @@ func New(opts ...Option) *App {
 		log:          logger,
 		startTimeout: DefaultTimeout,
 		stopTimeout:  DefaultTimeout,
+		graph:        graph.New(),
+		provided:     make(map[provideKey]fxreflect.Stack),
+		eagerNodes:   make(map[*graph.Node]struct{}),
 	}
 
+	app.options = opts
 	for _, opt := range opts {
 		opt.apply(app)
 	}
+	app.log = app.withDebugger(app.log)
 
 	// There are a few levels of wrapping on the lifecycle here. To quickly
 	// cover them:
@@ -523,7 +800,10 @@ func New(opts ...Option) *App {
 	// - appLogger ensures that the lifecycle always logs events to the
 	//   "current" logger associated with the fx.App.
 	app.lifecycle = &lifecycleWrapper{
-		lifecycle.New(appLogger{app}),
+		Lifecycle: lifecycle.New(appLogger{app}),
+	}
+	if app.recoverFromPanics {
+		app.lifecycle.RecoverFromPanics()
 	}
 
 	var (
@@ -549,17 +829,29 @@ func New(opts ...Option) *App {
 		dig.DryRun(app.validate),
 	)
 
+	app.logIfs()
+
 	for _, p := range app.provides {
 		app.provide(p)
 	}
 
 	frames := fxreflect.CallerStack(0, 0) // include New in the stack for default Provides
 	app.provide(provide{
-		Target: func() Lifecycle { return app.lifecycle },
-		Stack:  frames,
+		Target:    func() Lifecycle { return app.lifecycle },
+		Stack:     frames,
+		IsBuiltin: true,
 	})
-	app.provide(provide{Target: app.shutdowner, Stack: frames})
-	app.provide(provide{Target: app.dotGraph, Stack: frames})
+	app.provide(provide{Target: app.shutdowner, Stack: frames, IsBuiltin: true})
+	app.provide(provide{Target: app.dotGraph, Stack: frames, IsBuiltin: true})
+	app.inheritParent()
+	app.evaluateConditions()
+	app.provideDefaults()
+	app.provideScoped()
+	app.checkLazy()
+
+	// Graph is a snapshot taken when it's first requested, so it's only
+	// available once all options are applied.
+	app.provide(provide{Target: app.structuredGraph, Stack: frames, IsBuiltin: true})
 
 	if app.err != nil {
 		app.log.LogEvent(&fxevent.ProvideError{Err: app.err})
@@ -589,18 +881,45 @@ func New(opts ...Option) *App {
 		return app
 	}
 
-	if err := app.executeInvokes(); err != nil {
+	if err := app.whenErr; err != nil {
+		app.err = err
+		errorHandlerList(app.errorHooks).HandleError(err)
+		return app
+	}
+
+	calls := app.prepareInvokes()
+	if err := app.constructEager(); err != nil {
 		app.err = err
+		app.log.LogEvent(&fxevent.ProvideError{Err: err})
+		errorHandlerList(app.errorHooks).HandleError(err)
+		return app
+	}
 
-		if dig.CanVisualizeError(err) {
+	if err := app.executeInvokes(calls); err != nil {
+		app.err = err
+
+		var containerErr *containerError
+		if errors.As(err, &containerErr) && len(containerErr.failed) > 0 {
 			var b bytes.Buffer
-			dig.Visualize(app.container, &b, dig.VisualizeError(err))
+			app.graph.WriteDOT(&b, containerErr.failed)
 			err = errorWithGraph{
 				graph: b.String(),
 				err:   err,
 			}
 		}
 		errorHandlerList(app.errorHooks).HandleError(err)
+		return app
+	}
+
+	if err := app.lifecycle.err(); err != nil {
+		app.err = err
+		errorHandlerList(app.errorHooks).HandleError(err)
+		return app
+	}
+
+	if err := app.checkUnused(); err != nil {
+		app.err = err
+		errorHandlerList(app.errorHooks).HandleError(err)
 	}
 
 	return app
@@ -610,7 +929,7 @@ func New(opts ...Option) *App {
 // an Fx application. It is provided in the container by default at
 // initialization. On failure to build the dependency graph, it is attached
 // to the error and if possible, colorized to highlight the root cause of the
-// failure.
+// failure. See Graph for a structured alternative.
 type DotGraph string
 
 type errWithGraph interface {
@@ -630,6 +949,10 @@ func (err errorWithGraph) Error() string {
 	return err.err.Error()
 }
 
+func (err errorWithGraph) Unwrap() error {
+	return err.err
+}
+
 // VisualizeError returns the visualization of the error if available.
 func VisualizeError(err error) (string, error) {
 	if e, ok := err.(errWithGraph); ok && e.Graph() != "" {
@@ -680,7 +1003,8 @@ var (
 // Lifecycle, one at a time and in order. This ensures that each constructor's
 // start hooks aren't executed until all its dependencies' start hooks
 // complete. If any of the start hooks return an error, Start short-circuits,
-// calls Stop, and returns the inciting error.
+// calls Stop, and returns the inciting error. Children built with NewChild
+// that aren't running yet are started after the application's own hooks.
 //
 // Note that Start short-circuits immediately if the New constructor
 // encountered any errors in application initialization.
@@ -699,14 +1023,19 @@ func (app *App) Start(ctx context.Context) error {
 //
 // If the application didn't start cleanly, only hooks whose OnStart phase was
 // called are executed. However, all those hooks are executed, even if some
-// fail.
+// fail. Children built with NewChild are stopped before the application's
+// own hooks run.
 func (app *App) Stop(ctx context.Context) error {
-	return withTimeout(ctx, &withTimeoutParams{
+	err := withTimeout(ctx, &withTimeoutParams{
 		hook:      _onStopHook,
-		callback:  app.lifecycle.Stop,
+		callback:  app.stop,
 		lifecycle: app.lifecycle,
 		log:       app.log,
 	})
+	if app.parent != nil {
+		app.parent.removeChild(app)
+	}
+	return err
 }
 
 // Done returns a channel of signals to block on after starting the
@@ -742,10 +1071,14 @@ func (app *App) StopTimeout() time.Duration {
 
 func (app *App) dotGraph() (DotGraph, error) {
 	var b bytes.Buffer
-	err := dig.Visualize(app.container, &b)
+	err := app.graph.WriteDOT(&b, nil)
 	return DotGraph(b.String()), err
 }
 
+func (app *App) structuredGraph() Graph {
+	return newGraph(app.graph)
+}
+
 func (app *App) provide(p provide) {
 	if app.err != nil {
 		return
@@ -753,53 +1086,145 @@ func (app *App) provide(p provide) {
 
 	constructor := p.Target
 	if _, ok := constructor.(Option); ok {
-		app.err = fmt.Errorf("fx.Option should be passed to fx.New directly, "+
-			"not to fx.Provide: fx.Provide received %v from:\n%+v",
-			constructor, p.Stack)
+		app.err = &ProvideError{
+			Constructor: constructor,
+			Stack:       p.Stack,
+			option:      p.Option,
+			Err: fmt.Errorf("fx.Option should be passed to fx.New directly, "+
+				"not to fx.Provide: fx.Provide received %v", constructor),
+		}
 		return
 	}
 
+	// orig is how the constructor is reported in events, errors, and
+	// graphs.
+	orig := constructor
+	if p.Method != nil {
+		orig = p.Method
+	}
+
+	// The same option may be included by more than one library. Ignore
+	// constructors that were already provided rather than failing.
+	if p.Key != (provideKey{}) {
+		if source, ok := app.provided[p.Key]; ok {
+			app.log.LogEvent(&fxevent.Duplicate{
+				Constructor:        orig,
+				Stacktrace:         fmt.Sprintf("%+v", p.Source),
+				PreviousStacktrace: fmt.Sprintf("%+v", source),
+			})
+			return
+		}
+	}
+
 	var info dig.ProvideInfo
 	opts := []dig.ProvideOption{
 		dig.FillProvideInfo(&info),
 	}
+	// Set only if some of the values produced by the constructor were
+	// given an order. dig only knows about their hidden groups.
+	var resultNames []string
 	defer func() {
 		if app.err != nil {
 			return
 		}
 
+		if p.Key != (provideKey{}) {
+			app.provided[p.Key] = p.Source
+		}
+
+		outputNames := make([]string, len(info.Outputs))
+		for i, o := range info.Outputs {
+			outputNames[i] = o.String()
+		}
+		if resultNames != nil {
+			outputNames = resultNames
+		}
+
 		switch {
 		case p.IsSupply:
 			app.log.LogEvent(&fxevent.Supply{TypeName: p.SupplyType.String()})
+		case p.IsDefault:
+			app.log.LogEvent(&fxevent.Default{TypeName: strings.Join(outputNames, ", ")})
 		default:
-			outputNames := make([]string, len(info.Outputs))
-			for i, o := range info.Outputs {
-				outputNames[i] = o.String()
-			}
-
 			app.log.LogEvent(&fxevent.Provide{
-				Constructor:     constructor,
+				Constructor:     orig,
 				OutputTypeNames: outputNames,
 			})
 		}
 	}()
 
 	if ann, ok := constructor.(Annotated); ok {
+		// Values that have both a name and a group are provided with their
+		// name first, and added to their group by provideToGroup.
 		switch {
-		case len(ann.Group) > 0 && len(ann.Name) > 0:
-			app.err = fmt.Errorf(
-				"fx.Annotated may specify only one of Name or Group: received %v from:\n%+v",
-				ann, p.Stack)
-			return
 		case len(ann.Name) > 0:
 			opts = append(opts, dig.Name(ann.Name))
+		case len(ann.Group) > 0 && (ann.Order != 0 || len(ann.Key) > 0):
+			name, _ := splitGroup(ann.Group)
+			opts = append(opts, dig.Group(hiddenGroup(name)+",flatten"))
 		case len(ann.Group) > 0:
 			opts = append(opts, dig.Group(ann.Group))
+		}
 
+		target, err := envFunc(ann.Target)
+		target = app.lifecycleFunc(p, ann.Target, target)
+		node := &graph.Node{
+			Func:    ann.Target,
+			Stack:   p.Stack,
+			Params:  fxreflect.InspectSignature(target).Params,
+			Results: resultKeys(ann),
+			Builtin: p.IsBuiltin,
+		}
+		digTarget := target
+		if err == nil && (len(ann.Name) == 0 || len(ann.Group) == 0) {
+			digTarget, err = app.groupResults(target, &ann)
+		}
+		if err == nil {
+			err = app.container.Provide(app.wrapConstructor(p, node, digTarget), opts...)
+			if err == nil && len(ann.Name) > 0 && len(ann.Group) > 0 {
+				err = app.provideToGroup(target, ann)
+			}
+			if err != nil {
+				err = app.provideError(node, err)
+			}
+		}
+		if err != nil {
+			app.err = &ProvideError{
+				Constructor: ann,
+				Stack:       p.Stack,
+				Err:         err,
+				option:      p.Option,
+			}
+			return
 		}
 
-		if err := app.container.Provide(ann.Target, opts...); err != nil {
-			app.err = fmt.Errorf("fx.Provide(%v) from:\n%+vFailed: %v", ann, p.Stack, err)
+		orders := make([]int, len(node.Results))
+		keys := make([]string, len(node.Results))
+		for i, k := range node.Results {
+			if len(k.Group) > 0 {
+				orders[i], keys[i] = ann.Order, ann.Key
+			}
+		}
+		setGroupAnnotations(node, orders, keys)
+		if err := app.checkGroupKeys(node); err != nil {
+			app.err = &ProvideError{
+				Constructor: ann,
+				Stack:       p.Stack,
+				Err:         err,
+				option:      p.Option,
+			}
+			return
+		}
+		resultNames = resultStrings(node)
+		if len(ann.Name) > 0 && len(ann.Group) > 0 && resultNames == nil {
+			// dig only knows about the names of the values.
+			for _, k := range node.Results {
+				resultNames = append(resultNames, k.String())
+			}
+		}
+		app.graph.AddConstructor(node)
+		if ann.Eager {
+			app.eagerNodes[node] = struct{}{}
 		}
 		return
 	}
@@ -811,47 +1236,193 @@ func (app *App) provide(p provide) {
 			t := ft.Out(i)
 
 			if t == reflect.TypeOf(Annotated{}) {
-				app.err = fmt.Errorf(
-					"fx.Annotated should be passed to fx.Provide directly, "+
-						"it should not be returned by the constructor: "+
-						"fx.Provide received %v from:\n%+v",
-					fxreflect.FuncName(constructor), p.Stack)
+				app.err = &ProvideError{
+					Constructor: orig,
+					Stack:       p.Stack,
+					option:      p.Option,
+					Err: fmt.Errorf(
+						"fx.Annotated should be passed to fx.Provide directly, "+
+							"it should not be returned by the constructor: "+
+							"fx.Provide received %v", fxreflect.FuncName(orig)),
+				}
 				return
 			}
 		}
 	}
 
-	if err := app.container.Provide(constructor, opts...); err != nil {
-		app.err = fmt.Errorf("fx.Provide(%v) from:\n%+vFailed: %v", fxreflect.FuncName(constructor), p.Stack, err)
+	target, err := envFunc(constructor)
+	target = app.lifecycleFunc(p, orig, target)
+	sig := fxreflect.InspectSignature(target)
+	node := &graph.Node{
+		Func:    orig,
+		Stack:   p.Stack,
+		Params:  sig.Params,
+		Results: sig.Results,
+		Builtin: p.IsBuiltin,
+	}
+	var digTarget interface{}
+	if err == nil {
+		digTarget, err = app.groupResults(target, nil)
+	}
+	if err == nil {
+		if err = app.container.Provide(app.wrapConstructor(p, node, digTarget), opts...); err != nil {
+			err = app.provideError(node, err)
+		}
 	}
+	if err != nil {
+		app.err = &ProvideError{
+			Constructor: orig,
+			Stack:       p.Stack,
+			Err:         err,
+			option:      p.Option,
+		}
+		return
+	}
+
+	setGroupAnnotations(node, sig.Orders, sig.GroupKeys)
+	if err := app.checkGroupKeys(node); err != nil {
+		app.err = &ProvideError{
+			Constructor: orig,
+			Stack:       p.Stack,
+			Err:         err,
+			option:      p.Option,
+		}
+		return
+	}
+	resultNames = resultStrings(node)
+	app.graph.AddConstructor(node)
+}
+
+// resultKeys returns the keys of the values produced by the given
+// constructor, taking fx.Annotated into account.
+func resultKeys(constructor interface{}) []fxreflect.Key {
+	ann, ok := constructor.(Annotated)
+	if !ok {
+		return fxreflect.InspectSignature(constructor).Results
+	}
+
+	// Values that have both a name and a group are produced under both.
+	var results []fxreflect.Key
+	for _, k := range fxreflect.InspectSignature(ann.Target).Results {
+		if len(ann.Name) > 0 || len(ann.Group) == 0 {
+			results = append(results, fxreflect.ResultKey(k.Type, ann.Name, ""))
+		}
+		if len(ann.Group) > 0 {
+			results = append(results, fxreflect.ResultKey(k.Type, "", ann.Group))
+		}
+	}
+	return results
+}
+
+// invocation is an invoke prepared to be run by executeInvokes.
+type invocation struct {
+	// Function passed to the container, and the error that prevents it
+	// from being invoked, if any.
+	target interface{}
+	err    error
+
+	// Node recorded for the invoke in the graph, if any.
+	node *graph.Node
+
+	// Set only for functions passed to fx.InvokeAfterStart.
+	afterStart *afterStart
+}
+
+// prepareInvokes records all invokes in the graph before any of them runs,
+// and before constructors are called eagerly, so that constructors can find
+// out who consumes their values.
+func (app *App) prepareInvokes() []invocation {
+	calls := make([]invocation, len(app.invokes))
+	for idx, i := range app.invokes {
+		call := &calls[idx]
+		if _, ok := i.Target.(Option); ok {
+			call.err = fmt.Errorf("fx.Option should be passed to fx.New directly, "+
+				"not to fx.Invoke: fx.Invoke received %v from:\n%+v",
+				i.Target, i.Stack)
+			continue
+		}
+
+		if i.Provided {
+			results := resultKeys(i.Target)
+			params := make([]fxreflect.Param, len(results))
+			for j, k := range results {
+				params[j] = fxreflect.Param{Key: k}
+			}
+			fn := i.Target
+			if ann, ok := fn.(Annotated); ok {
+				fn = ann.Target
+			}
+			call.target = newConsumer(results, nil)
+			call.node = &graph.Node{
+				Func:   fn,
+				Stack:  i.Stack,
+				Params: params,
+			}
+			app.graph.AddInvoke(call.node)
+			continue
+		}
+
+		fn := i.Target
+		if i.AfterStart && reflect.TypeOf(fn) != nil && reflect.TypeOf(fn).Kind() == reflect.Func {
+			call.afterStart = &afterStart{Function: i.Target, Stack: i.Stack}
+			fn = call.afterStart.capture()
+		}
+
+		call.target, call.err = envFunc(fn)
+		call.node = &graph.Node{
+			Func:   i.Target,
+			Stack:  i.Stack,
+			Params: fxreflect.InspectSignature(call.target).Params,
+		}
+		app.graph.AddInvoke(call.node)
+
+		if call.err == nil {
+			call.target = app.invokeGroupFunc(app.lazyFunc(call.target))
+			if app.recoverFromPanics {
+				call.target = recoverFunc(i.Target, i.Stack, call.target)
+			}
+		}
+	}
+	return calls
 }
 
 // Execute invokes in order supplied to New, returning the first error
-// encountered.
-func (app *App) executeInvokes() error {
+// encountered. calls are the invokes returned by prepareInvokes.
+func (app *App) executeInvokes(calls []invocation) error {
 	// TODO: consider taking a context to limit the time spent running invocations.
 
-	for _, i := range app.invokes {
-		fn := i.Target
-		app.log.LogEvent(&fxevent.Invoke{Function: fn})
+	for idx, i := range app.invokes {
+		fn, call := i.Target, calls[idx]
+		if !i.AfterStart {
+			app.log.LogEvent(&fxevent.Invoke{Function: fn})
+		}
 
-		var err error
-		if _, ok := fn.(Option); ok {
-			err = fmt.Errorf("fx.Option should be passed to fx.New directly, "+
-				"not to fx.Invoke: fx.Invoke received %v from:\n%+v",
-				fn, i.Stack)
-		} else {
-			err = app.container.Invoke(fn)
+		err := call.err
+		if err == nil {
+			err = app.containerError(call.node, app.container.Invoke(call.target))
 		}
 
 		if err != nil {
+			invokeErr := &InvokeError{
+				Function: fn,
+				Stack:    i.Stack,
+				Err:      err,
+			}
+			if call.node != nil {
+				invokeErr.explanation = explainMissing(app.graph, call.node)
+			}
+
 			app.log.LogEvent(&fxevent.InvokeError{
 				Function:   fn,
-				Err:        err,
+				Err:        invokeErr,
 				Stacktrace: fmt.Sprintf("%+v", i.Stack), // format stack trace as multi-line
 			})
 
-			return err
+			return invokeErr
+		}
+
+		if call.afterStart != nil {
+			app.afterStart = append(app.afterStart, call.afterStart)
 		}
 	}
 
@@ -884,11 +1455,23 @@ func (app *App) start(ctx context.Context) error {
 		return app.err
 	}
 
-	// Attempt to start cleanly.
-	if err := app.lifecycle.Start(ctx); err != nil {
-		// Start failed, rolling back.
+	if app.parent != nil && app.isRunning() {
+		// Children may be started by their parent as well as directly.
+		return nil
+	}
+
+	// Attempt to start cleanly, along with any children.
+	err := app.lifecycle.Start(ctx)
+	if err == nil {
+		err = app.startChildren(ctx)
+	}
+	if err == nil {
+		err = app.runAfterStart()
+	}
+	if err != nil {
+		// Start failed, rolling back the children and hooks that started.
 		app.log.LogEvent(&fxevent.Rollback{StartErr: err})
-		if stopErr := app.lifecycle.Stop(ctx); stopErr != nil {
+		if stopErr := app.stop(ctx); stopErr != nil {
 			app.log.LogEvent(&fxevent.RollbackError{Err: stopErr})
 
 			return multierr.Append(err, stopErr)
@@ -896,11 +1479,20 @@ func (app *App) start(ctx context.Context) error {
 
 		return err
 	}
+	app.setRunning(true)
 	app.log.LogEvent(&fxevent.Running{})
 
 	return nil
 }
 
+func (app *App) stop(ctx context.Context) error {
+	// Children are stopped before the hooks they may depend on.
+	err := app.stopChildren(ctx)
+	err = multierr.Append(err, app.lifecycle.Stop(ctx))
+	app.setRunning(false)
+	return err
+}
+
 type withTimeoutParams struct {
 	log       fxevent.Logger
 	hook      string
@@ -80,7 +80,7 @@ func TestNewApp(t *testing.T) {
 			WithLogger(func() fxevent.Logger { return spy }))
 		defer app.RequireStart().RequireStop()
 		require.Equal(t,
-			[]string{"Provide", "Provide", "Provide", "Provide", "CustomLogger", "Running"},
+			[]string{"Provide", "Provide", "Provide", "Provide", "Provide", "CustomLogger", "Running"},
 			spy.EventTypes())
 
 		assert.Contains(t, spy.Events()[0].(*fxevent.Provide).OutputTypeNames, "struct {}")
@@ -204,33 +204,6 @@ func TestNewApp(t *testing.T) {
 		require.NoError(t, app.Err())
 	})
 
-	t.Run("CannotNameAndGroup", func(t *testing.T) {
-		type A struct{}
-
-		app := NewForTest(t,
-			Provide(
-				Annotated{
-					Target: func() A { return A{} },
-					Name:   "foo",
-					Group:  "bar",
-				},
-			),
-		)
-
-		err := app.Err()
-		require.Error(t, err)
-
-		// fx.Annotated may specify only one of Name or Group: received fx.Annotated{Name: "foo", Group: "bar", Target: go.uber.org/fx_test.TestAnnotatedWithGroupAndName.func1()} from:
-		// go.uber.org/fx_test.TestAnnotatedWithGroupAndName
-		//         /.../fx/annotated_test.go:164
-		// testing.tRunner
-		//         /.../go/1.13.3/libexec/src/testing/testing.go:909
-		assert.Contains(t, err.Error(), "fx.Annotated may specify only one of Name or Group:")
-		assert.Contains(t, err.Error(), `received fx.Annotated{Name: "foo", Group: "bar", Target: go.uber.org/fx_test.TestNewApp`)
-		assert.Contains(t, err.Error(), "go.uber.org/fx_test.TestNewApp")
-		assert.Contains(t, err.Error(), "fx/app_test.go")
-	})
-
 	t.Run("ErrorProvidingAnnotated", func(t *testing.T) {
 		app := NewForTest(t, Provide(Annotated{
 			Target: 42, // not a constructor
@@ -286,7 +259,7 @@ func TestWithLogger(t *testing.T) {
 		)
 
 		assert.Equal(t, []string{
-			"Supply", "Provide", "Provide", "Provide", "CustomLogger",
+			"Supply", "Provide", "Provide", "Provide", "Provide", "CustomLogger",
 		}, spy.EventTypes())
 
 		spy.Reset()
@@ -519,6 +492,183 @@ func TestOptions(t *testing.T) {
 		assert.Equal(t, 2, n)
 	})
 
+	t.Run("DuplicateOptionsAreIgnored", func(t *testing.T) {
+		type A struct{}
+
+		var calls int
+		newA := func() *A {
+			calls++
+			return &A{}
+		}
+		module := Provide(newA)
+
+		spy := new(fxlog.Spy)
+		app := fxtest.New(t,
+			WithLogger(func() fxevent.Logger { return spy }),
+			Options(module),
+			Options(Options(module)),
+			module,
+			Invoke(func(*A) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, 1, calls)
+
+		var dupes []*fxevent.Duplicate
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Duplicate); ok {
+				dupes = append(dupes, e)
+			}
+		}
+		require.Len(t, dupes, 2)
+		for _, e := range dupes {
+			assert.Contains(t, e.Stacktrace, "app_test.go")
+			assert.Contains(t, e.PreviousStacktrace, "app_test.go")
+			assert.NotEqual(t, e.Stacktrace, e.PreviousStacktrace,
+				"stacks must tell apart where the option was included from")
+		}
+	})
+
+	t.Run("DuplicatesRespectAnnotations", func(t *testing.T) {
+		type A struct{}
+		newA := func() *A { return &A{} }
+		module := Provide(
+			Annotated{Name: "foo", Target: newA},
+			Annotated{Name: "bar", Target: newA},
+		)
+
+		var got struct {
+			In
+
+			Foo *A `name:"foo"`
+			Bar *A `name:"bar"`
+		}
+		app := fxtest.New(t,
+			module,
+			module,
+			Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.NotNil(t, got.Foo)
+		assert.NotNil(t, got.Bar)
+	})
+
+	t.Run("SeparateOptionsAreDuplicates", func(t *testing.T) {
+		type A struct{}
+
+		var calls int
+		newA := func() *A {
+			calls++
+			return &A{}
+		}
+
+		spy := new(fxlog.Spy)
+		app := fxtest.New(t,
+			WithLogger(func() fxevent.Logger { return spy }),
+			Provide(newA),
+			Provide(newA),
+			Provide(Annotated{Name: "foo", Target: newA}),
+			Provide(Annotated{Name: "foo", Target: newA}),
+			Invoke(func(*A) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, 1, calls)
+
+		var dupes int
+		for _, ev := range spy.Events() {
+			if _, ok := ev.(*fxevent.Duplicate); ok {
+				dupes++
+			}
+		}
+		assert.Equal(t, 2, dupes)
+	})
+
+	t.Run("SuppliedValues", func(t *testing.T) {
+		type A struct{}
+
+		module := Supply(&A{})
+		app := fxtest.New(t, module, module, Invoke(func(*A) {}))
+		defer app.RequireStart().RequireStop()
+
+		err := NewForTest(t, Supply(&A{}), Supply(&A{})).Err()
+		require.Error(t, err, "separate calls to Supply must not be duplicates")
+		assert.Contains(t, err.Error(), "already provided")
+	})
+
+	t.Run("DistinctClosuresAreNotDuplicates", func(t *testing.T) {
+		newProvider := func(s string) interface{} {
+			return Annotated{
+				Group:  "strings",
+				Target: func() string { return s },
+			}
+		}
+
+		var got struct {
+			In
+
+			Strings []string `group:"strings"`
+		}
+		app := fxtest.New(t,
+			Provide(newProvider("a"), newProvider("b")),
+			Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.ElementsMatch(t, []string{"a", "b"}, got.Strings)
+	})
+
+	t.Run("DuplicateRequestScopedAreIgnored", func(t *testing.T) {
+		type A struct{}
+
+		var calls int
+		newA := func() *A {
+			calls++
+			return &A{}
+		}
+		module := RequestScoped(newA)
+
+		spy := new(fxlog.Spy)
+		var scopes *Scopes
+		app := fxtest.New(t,
+			WithLogger(func() fxevent.Logger { return spy }),
+			module,
+			module,
+			Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, scope.Invoke(func(*A) {}))
+		require.NoError(t, scope.End(context.Background()))
+		assert.Equal(t, 1, calls)
+
+		var dupes []*fxevent.Duplicate
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Duplicate); ok {
+				dupes = append(dupes, e)
+			}
+		}
+		require.Len(t, dupes, 1)
+		assert.Contains(t, dupes[0].Stacktrace, "app_test.go")
+	})
+
+	t.Run("FirstDefaultIsUsed", func(t *testing.T) {
+		type A struct{ Name string }
+
+		var got *A
+		app := fxtest.New(t,
+			Default(&A{Name: "first"}),
+			Default(&A{Name: "second"}),
+			Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, "first", got.Name)
+	})
+
 	t.Run("ProvidesCalledInGraphOrder", func(t *testing.T) {
 		type type1 struct{}
 		type type2 struct{}
@@ -774,6 +924,7 @@ func TestAppStart(t *testing.T) {
 		)
 		err := app.Start(context.Background())
 		require.Error(t, err)
+
 		assert.Equal(t, []error{errStart2, errStop1}, multierr.Errors(err))
 	})
 
@@ -793,7 +944,7 @@ func TestAppStart(t *testing.T) {
 		//         /.../go/1.13.3/libexec/src/testing/testing.go:909
 		// Failed: can't invoke non-function {} (type struct {})
 		require.Equal(t,
-			[]string{"Provide", "Provide", "Provide", "CustomLogger", "Invoke", "InvokeError"},
+			[]string{"Provide", "Provide", "Provide", "Provide", "CustomLogger", "Invoke", "InvokeError"},
 			spy.EventTypes())
 		failedEvent := spy.Events()[len(spy.EventTypes())-1].(*fxevent.InvokeError)
 		assert.Contains(t, failedEvent.Err.Error(), "can't invoke non-function")
@@ -829,6 +980,11 @@ func TestAppStart(t *testing.T) {
 		assert.Contains(t, err.Error(), "fx.Provide received fx.Provide(go.uber.org/fx_test.TestAppStart")
 		assert.Contains(t, err.Error(), "go.uber.org/fx_test.TestAppStart")
 		assert.Contains(t, err.Error(), "fx/app_test.go")
+
+		var provideErr *ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+		assert.Implements(t, (*Option)(nil), provideErr.Constructor)
+		assert.Contains(t, provideErr.Stack.String(), "fx/app_test.go")
 	})
 
 	t.Run("InvokingAnInvokeShouldFail", func(t *testing.T) {
@@ -892,6 +1048,11 @@ func TestAppStart(t *testing.T) {
 		assert.Contains(t, err.Error(), "fx.Provide received fx.Options(fx.Provide(go.uber.org/fx_test.TestAppStart")
 		assert.Contains(t, err.Error(), "go.uber.org/fx_test.TestAppStart")
 		assert.Contains(t, err.Error(), "fx/app_test.go")
+
+		var provideErr *ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+		assert.Equal(t, module, provideErr.Constructor)
+		assert.Contains(t, provideErr.Stack.String(), "fx/app_test.go")
 	})
 }
 
@@ -1024,7 +1185,7 @@ func TestReplaceLogger(t *testing.T) {
 	spy := new(fxlog.Spy)
 	app := fxtest.New(t, WithLogger(func() fxevent.Logger { return spy }))
 	app.RequireStart().RequireStop()
-	assert.Equal(t, []string{"Provide", "Provide", "Provide", "CustomLogger", "Running"}, spy.EventTypes())
+	assert.Equal(t, []string{"Provide", "Provide", "Provide", "Provide", "CustomLogger", "Running"}, spy.EventTypes())
 }
 
 func TestNopLogger(t *testing.T) {
@@ -1093,6 +1254,7 @@ func TestCustomLoggerWithLifecycle(t *testing.T) {
 		"Provide",
 		"Provide",
 		"Provide",
+		"Provide",
 		"CustomLogger",
 		"LifecycleHookExecuting",
 		"LifecycleHookExecuted",
@@ -1254,6 +1416,72 @@ func TestOptionString(t *testing.T) {
 			give: Supply(Annotated{Target: bytes.NewReader(nil)}),
 			want: "fx.Supply(*bytes.Reader)",
 		},
+		{
+			desc: "Default",
+			give: Default(bytes.NewReader(nil), Annotated{Name: "buf", Target: bytes.NewBuffer(nil)}),
+			want: "fx.Default(*bytes.Reader, *bytes.Buffer)",
+		},
+		{
+			desc: "DebugHandler",
+			give: DebugHandler,
+			want: "fx.DebugHandler",
+		},
+		{
+			desc: "Strict",
+			give: Strict(),
+			want: "fx.Strict()",
+		},
+		{
+			desc: "ReportUnused",
+			give: ReportUnused(),
+			want: "fx.ReportUnused()",
+		},
+		{
+			desc: "Eager",
+			give: Eager(),
+			want: "fx.Eager()",
+		},
+		{
+			desc: "RecoverFromPanics",
+			give: RecoverFromPanics(),
+			want: "fx.RecoverFromPanics()",
+		},
+		{
+			desc: "RequestScoped",
+			give: RequestScoped(bytes.NewReader, bytes.NewBuffer),
+			want: "fx.RequestScoped(bytes.NewReader(), bytes.NewBuffer())",
+		},
+		{
+			desc: "InvokeAndProvide",
+			give: InvokeAndProvide(bytes.NewReader, bytes.NewBuffer),
+			want: "fx.InvokeAndProvide(bytes.NewReader(), bytes.NewBuffer())",
+		},
+		{
+			desc: "InvokeAfterStart",
+			give: InvokeAfterStart(bytes.NewReader, bytes.NewBuffer),
+			want: "fx.InvokeAfterStart(bytes.NewReader(), bytes.NewBuffer())",
+		},
+		{
+			desc: "AutoLifecycle",
+			give: AutoLifecycle(),
+			want: "fx.AutoLifecycle()",
+		},
+		{
+			desc: "ProvideStruct",
+			give: ProvideStruct(&bytes.Buffer{}),
+			want: "fx.ProvideStruct(*bytes.Buffer)",
+		},
+		{
+			desc: "If",
+			give: If(true, Provide(bytes.NewReader)),
+			want: "fx.If(true, fx.Options(fx.Provide(bytes.NewReader())))",
+		},
+		{
+			desc: "When",
+			give: When(func() bool { return true }, Provide(bytes.NewReader)),
+			want: "fx.When(go.uber.org/fx_test.TestOptionString.func4(), " +
+				"fx.Options(fx.Provide(bytes.NewReader())))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,189 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+
+	"go.uber.org/dig"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/lifecycle"
+)
+
+// AutoLifecycle appends lifecycle hooks for the values produced by
+// constructors, so that constructors don't need to request a Lifecycle to
+// start and stop the values they build. Values are started and stopped
+// with the following methods, if they have them:
+//
+//  Start(context.Context) error // runs on start
+//  Stop(context.Context) error  // runs on stop
+//  Close() error                // runs on stop, if there's no Stop method
+//
+// The hooks are appended when the constructors run, so values are started
+// in the order they were built and stopped in the reverse order, along
+// with the hooks appended by constructors themselves. Fields of result
+// structs that embed fx.Out are considered as well. Values passed to
+// fx.Supply or fx.Default are not.
+//
+// Hooks are appended once per value: a pointer returned by more than one
+// constructor, for example as different types, is started and stopped
+// once. Constructors that already append hooks to start or stop the values
+// they return will have them started or stopped twice.
+func AutoLifecycle() Option {
+	return autoLifecycleOption{Stack: fxreflect.CallerStack(1, 0)}
+}
+
+type autoLifecycleOption struct {
+	Stack fxreflect.Stack
+}
+
+func (autoLifecycleOption) apply(app *App) {
+	app.autoLifecycle = true
+}
+
+func (o autoLifecycleOption) visit(v *optionVisitor) {
+	v.report(o, "fx.AutoLifecycle", o.Stack)
+}
+
+func (autoLifecycleOption) String() string {
+	return "fx.AutoLifecycle()"
+}
+
+type (
+	starter interface{ Start(context.Context) error }
+	stopper interface{ Stop(context.Context) error }
+)
+
+var _typeOfOut = reflect.TypeOf(Out{})
+
+// autoHookKey identifies a value that hooks were appended for by the
+// address it refers to and its dynamic type.
+type autoHookKey struct {
+	Type    reflect.Type
+	Pointer uintptr
+}
+
+// lifecycleFunc returns a function with the same behavior as the
+// constructor target, except that it appends hooks for the values it
+// produces if the application was built with AutoLifecycle. orig is the
+// constructor as it was provided to Fx.
+func (app *App) lifecycleFunc(p provide, orig, target interface{}) interface{} {
+	fv := reflect.ValueOf(target)
+	if !app.autoLifecycle || p.IsSupply || p.IsDefault || p.IsBuiltin || fv.Kind() != reflect.Func {
+		return target
+	}
+	ft := fv.Type()
+
+	// Hooks are attributed to the constructor, as if it appended them.
+	caller := fxreflect.Frame{Function: strings.TrimSuffix(fxreflect.FuncName(orig), "()")}
+	if f, ok := p.Stack.Caller(); ok {
+		caller.File, caller.Line = f.File, f.Line
+	}
+
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		var results []reflect.Value
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+
+		values := results
+		if n := len(results); n > 0 && ft.Out(n-1) == _typeOfError {
+			if !results[n-1].IsNil() {
+				// The constructor failed.
+				return results
+			}
+			values = results[:n-1]
+		}
+		for _, v := range values {
+			app.appendHooks(v, caller)
+		}
+		return results
+	}).Interface()
+}
+
+// appendHooks appends a hook for v if it can be started or stopped, or
+// for its fields if it's a result struct.
+func (app *App) appendHooks(v reflect.Value, caller fxreflect.Frame) {
+	if dig.IsOut(v.Type()) {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			switch {
+			case len(f.PkgPath) > 0 || f.Type == _typeOfOut:
+				// Unexported fields and fx.Out itself.
+			case f.Type.Kind() == reflect.Slice && strings.HasSuffix(f.Tag.Get("group"), ",flatten"):
+				for j := 0; j < v.Field(i).Len(); j++ {
+					app.appendHooks(v.Field(i).Index(j), caller)
+				}
+			default:
+				app.appendHooks(v.Field(i), caller)
+			}
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+	}
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	// Values that refer to the same address were already hooked if they
+	// were returned before, possibly as a different type.
+	var key autoHookKey
+	switch v.Kind() {
+	case reflect.Chan, reflect.Map, reflect.Ptr, reflect.UnsafePointer:
+		key = autoHookKey{Type: v.Type(), Pointer: v.Pointer()}
+		if _, ok := app.autoHooked[key]; ok {
+			return
+		}
+	}
+
+	var hook lifecycle.Hook
+	value := v.Interface()
+	if s, ok := value.(starter); ok {
+		hook.OnStart = s.Start
+	}
+	if s, ok := value.(stopper); ok {
+		hook.OnStop = s.Stop
+	} else if c, ok := value.(io.Closer); ok {
+		hook.OnStop = func(context.Context) error { return c.Close() }
+	}
+	if hook.OnStart == nil && hook.OnStop == nil {
+		return
+	}
+
+	app.lifecycle.AppendFrom(hook, caller)
+	if key != (autoHookKey{}) {
+		if app.autoHooked == nil {
+			app.autoHooked = make(map[autoHookKey]struct{})
+		}
+		app.autoHooked[key] = struct{}{}
+	}
+}
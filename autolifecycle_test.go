@@ -0,0 +1,175 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type autoService struct {
+	name  string
+	calls *[]string
+}
+
+func (s *autoService) Start(context.Context) error {
+	*s.calls = append(*s.calls, "start "+s.name)
+	return nil
+}
+
+func (s *autoService) Stop(context.Context) error {
+	*s.calls = append(*s.calls, "stop "+s.name)
+	return nil
+}
+
+type autoCloser struct {
+	calls *[]string
+}
+
+func (c *autoCloser) Close() error {
+	*c.calls = append(*c.calls, "close")
+	return nil
+}
+
+func TestAutoLifecycle(t *testing.T) {
+	type server struct{ *autoService }
+	type db struct{ *autoService }
+
+	t.Run("ConstructionOrder", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.AutoLifecycle(),
+			fx.Provide(
+				func(d *db) *server {
+					return &server{&autoService{"server", &calls}}
+				},
+				func() *db {
+					return &db{&autoService{"db", &calls}}
+				},
+				func() *autoCloser {
+					return &autoCloser{&calls}
+				},
+			),
+			fx.Invoke(func(*server, *autoCloser) {}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{
+			"start db",
+			"start server",
+			"close",
+			"stop server",
+			"stop db",
+		}, calls)
+	})
+
+	t.Run("ResultStructs", func(t *testing.T) {
+		type result struct {
+			fx.Out
+
+			DB      *db
+			Closers []*autoCloser `group:"closers,flatten"`
+		}
+
+		var calls []string
+		app := fxtest.New(t,
+			fx.AutoLifecycle(),
+			fx.Provide(func() result {
+				return result{
+					DB:      &db{&autoService{"db", &calls}},
+					Closers: []*autoCloser{{&calls}, {&calls}},
+				}
+			}),
+			fx.Invoke(func(*db) {}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"start db", "close", "close", "stop db"}, calls)
+	})
+
+	t.Run("SameValue", func(t *testing.T) {
+		type service interface {
+			Start(context.Context) error
+		}
+
+		var calls []string
+		app := fxtest.New(t,
+			fx.AutoLifecycle(),
+			fx.Provide(
+				func() *autoService {
+					return &autoService{"service", &calls}
+				},
+				func(s *autoService) service { return s },
+			),
+			fx.Invoke(func(*autoService, service) {}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"start service", "stop service"}, calls)
+	})
+
+	t.Run("SkipsSupplied", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.AutoLifecycle(),
+			fx.Supply(&autoCloser{&calls}),
+			fx.Invoke(func(*autoCloser) {}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Empty(t, calls)
+	})
+
+	t.Run("SkipsFailed", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.AutoLifecycle(),
+			fx.Provide(func() (*db, error) {
+				return &db{&autoService{"db", &calls}}, errors.New("great sadness")
+			}),
+			fx.Invoke(func(newDB func() (*db, error)) {
+				_, err := newDB()
+				assert.Error(t, err)
+			}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Empty(t, calls)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.Provide(func() *db {
+				return &db{&autoService{"db", &calls}}
+			}),
+			fx.Invoke(func(*db) {}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Empty(t, calls)
+	})
+}
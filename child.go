@@ -0,0 +1,265 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/dig"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+	"go.uber.org/multierr"
+)
+
+// NewChild builds an application from the given options that shares the
+// values of app. Types provided to app, other than the ones Fx provides
+// itself, may be requested by the constructors and invokes of the child,
+// and resolve to the same instances as they do in app. Types provided to
+// the child take precedence over the ones provided to app, so that each
+// child gets its own instances of them. Value groups of the child hold the
+// values of app along with its own.
+//
+// The child has its own Lifecycle. Its hooks run after the hooks of app
+// when app starts, and before them when app stops. A child may also be
+// started and stopped independently, for example if it's built after app
+// started. Starting a child that's already running does nothing, and
+// stopping a child detaches it from app.
+//
+//  tenant := app.NewChild(
+//    fx.Provide(newTenantDB),
+//    fx.Invoke(func(db *TenantDB, log *zap.Logger) { ... }),
+//  )
+//  if err := tenant.Start(ctx); err != nil { ... }
+//
+// Children log to the logger of app unless they're given their own.
+func (app *App) NewChild(opts ...Option) *App {
+	child := New(append([]Option{childOption{parent: app}}, opts...)...)
+	if child.err == nil {
+		app.childrenMu.Lock()
+		app.children = append(app.children, child)
+		app.childrenMu.Unlock()
+	}
+	return child
+}
+
+type childOption struct {
+	parent *App
+}
+
+func (o childOption) apply(app *App) {
+	app.parent = o.parent
+	app.log = o.parent.log
+	if err := o.parent.err; err != nil {
+		app.err = fmt.Errorf("cannot build a child of an application that failed to build: %v", err)
+	}
+}
+
+// childOption is only used by NewChild, so there's nothing to report.
+func (childOption) visit(*optionVisitor) {}
+
+func (childOption) String() string {
+	return "fx.NewChild()"
+}
+
+// inheritParent provides the values of the parent application, if any, to
+// the container, except for the ones that were already provided to it.
+func (app *App) inheritParent() {
+	if app.err != nil || app.parent == nil {
+		return
+	}
+
+	parent := app.parent
+	groups := make(map[fxreflect.Key]struct{})
+	for _, n := range parent.graph.Constructors {
+		if n.Builtin && !parent.inherited[n] {
+			continue
+		}
+
+		for _, k := range n.Results {
+			if len(k.Group) > 0 {
+				if _, ok := groups[k]; ok {
+					continue
+				}
+				groups[k] = struct{}{}
+			} else if len(app.graph.Providers(k)) > 0 {
+				continue
+			}
+
+			if err := app.inherit(n, k); err != nil {
+				app.err = err
+				return
+			}
+		}
+	}
+}
+
+// inherit provides the value of the parent application with the given key,
+// which is built by the constructor n of the parent.
+func (app *App) inherit(n *graph.Node, k fxreflect.Key) error {
+	var (
+		t    = k.Type
+		opts []dig.ProvideOption
+	)
+	switch {
+	case len(k.Name) > 0:
+		opts = append(opts, dig.Name(k.Name))
+	case len(k.Group) > 0:
+		// The parent's values are added to the group one by one.
+		t = reflect.SliceOf(k.Type)
+		opts = append(opts, dig.Group(k.Group+",flatten"))
+	}
+
+	// Inherited values are reported as built by the parent's constructor.
+	// Like other values Fx provides, they don't need to be used.
+	node := &graph.Node{
+		Func:    n.Func,
+		Stack:   n.Stack,
+		Results: []fxreflect.Key{k},
+		Builtin: true,
+	}
+
+	fnType := reflect.FuncOf(nil, []reflect.Type{t, _typeOfError}, false /* variadic */)
+	fn := reflect.MakeFunc(fnType, func([]reflect.Value) []reflect.Value {
+		v, err := app.parent.value(k)
+		if err != nil {
+			err = &runError{node: node, err: err}
+			return []reflect.Value{reflect.Zero(t), reflect.ValueOf(&err).Elem()}
+		}
+		return []reflect.Value{v, reflect.Zero(_typeOfError)}
+	}).Interface()
+	if err := app.container.Provide(fn, opts...); err != nil {
+		return fmt.Errorf("cannot inherit %v from the parent application: %v", k, err)
+	}
+
+	app.graph.AddConstructor(node)
+	if app.inherited == nil {
+		app.inherited = make(map[*graph.Node]bool)
+	}
+	app.inherited[node] = true
+	return nil
+}
+
+// value requests the value with the given key from the container after the
+// application was built, on behalf of a child application or a lazy
+// function. Values of groups are returned as a slice. If the application is
+// running, hooks appended by the constructors that ran are started.
+func (app *App) value(k fxreflect.Key) (reflect.Value, error) {
+	// Children and lazy functions may request values concurrently.
+	app.containerMu.Lock()
+	v, err := app.resolve(k)
+	app.containerMu.Unlock()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	// Constructors that ran to build the value after the application
+	// started may have appended hooks that Start didn't run.
+	ctx, cancel := context.WithTimeout(context.Background(), app.startTimeout)
+	defer cancel()
+	if err := app.lifecycle.startAppended(ctx); err != nil {
+		return reflect.Value{}, err
+	}
+	return v, nil
+}
+
+// resolve requests the value with the given key from the container. Values
+// of groups are returned as a slice.
+//
+// Callers must hold containerMu, unless they run within a call made to the
+// container already, like constructors do.
+func (app *App) resolve(k fxreflect.Key) (reflect.Value, error) {
+	var value reflect.Value
+	err := app.container.Invoke(newConsumer([]fxreflect.Key{k}, func(values []reflect.Value) {
+		value = values[0]
+	}))
+	if err != nil {
+		return reflect.Value{}, app.containerError(consumerNode([]fxreflect.Key{k}), err)
+	}
+	return value, nil
+}
+
+// isRunning reports whether the application was started and hasn't been
+// stopped since.
+func (app *App) isRunning() bool {
+	app.childrenMu.Lock()
+	defer app.childrenMu.Unlock()
+	return app.running
+}
+
+func (app *App) setRunning(running bool) {
+	app.childrenMu.Lock()
+	defer app.childrenMu.Unlock()
+	app.running = running
+}
+
+// startChildren starts the children of the application that aren't
+// running yet, in the order they were built. If one of them fails, the
+// ones started before it are stopped.
+func (app *App) startChildren(ctx context.Context) error {
+	app.childrenMu.Lock()
+	children := append([]*App(nil), app.children...)
+	app.childrenMu.Unlock()
+
+	var started []*App
+	for _, child := range children {
+		if child.isRunning() {
+			continue
+		}
+
+		if err := child.start(ctx); err != nil {
+			for i := len(started) - 1; i >= 0; i-- {
+				err = multierr.Append(err, started[i].stop(ctx))
+			}
+			return err
+		}
+		started = append(started, child)
+	}
+	return nil
+}
+
+// stopChildren stops the children of the application in the reverse of
+// the order they were built.
+func (app *App) stopChildren(ctx context.Context) error {
+	app.childrenMu.Lock()
+	children := append([]*App(nil), app.children...)
+	app.childrenMu.Unlock()
+
+	var err error
+	for i := len(children) - 1; i >= 0; i-- {
+		err = multierr.Append(err, children[i].stop(ctx))
+	}
+	return err
+}
+
+// removeChild detaches the given child from the application.
+func (app *App) removeChild(child *App) {
+	app.childrenMu.Lock()
+	defer app.childrenMu.Unlock()
+
+	for i, c := range app.children {
+		if c == child {
+			app.children = append(app.children[:i], app.children[i+1:]...)
+			return
+		}
+	}
+}
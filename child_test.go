@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestNewChild(t *testing.T) {
+	type A struct{ Name string }
+	type B struct{ A *A }
+
+	t.Run("SharesParentValues", func(t *testing.T) {
+		var calls int
+		newA := func() *A {
+			calls++
+			return &A{Name: "parent"}
+		}
+
+		var parentA *A
+		app := fxtest.New(t, fx.Provide(newA), fx.Populate(&parentA))
+
+		var b *B
+		child := app.NewChild(
+			fx.Strict(),
+			fx.Provide(func(a *A) *B { return &B{A: a} }),
+			fx.Populate(&b),
+		)
+		require.NoError(t, child.Err())
+		assert.Same(t, parentA, b.A)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ChildValuesTakePrecedence", func(t *testing.T) {
+		var parentA *A
+		app := fxtest.New(t,
+			fx.Provide(func() *A { return &A{Name: "parent"} }),
+			fx.Populate(&parentA),
+		)
+
+		var childA *A
+		child := app.NewChild(
+			fx.Provide(func() *A { return &A{Name: "child"} }),
+			fx.Populate(&childA),
+		)
+		require.NoError(t, child.Err())
+		assert.Equal(t, "parent", parentA.Name)
+		assert.Equal(t, "child", childA.Name)
+	})
+
+	t.Run("NamedValuesAndGroups", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Name: "primary", Target: func() *A { return &A{Name: "primary"} }},
+				fx.Annotated{Group: "as", Target: func() *A { return &A{Name: "parent"} }},
+			),
+		)
+
+		var got struct {
+			fx.In
+
+			Primary *A   `name:"primary"`
+			As      []*A `group:"as"`
+		}
+		child := app.NewChild(
+			fx.Provide(fx.Annotated{Group: "as", Target: func() *A { return &A{Name: "child"} }}),
+			fx.Populate(&got),
+		)
+		require.NoError(t, child.Err())
+		assert.Equal(t, "primary", got.Primary.Name)
+
+		var names []string
+		for _, a := range got.As {
+			names = append(names, a.Name)
+		}
+		assert.ElementsMatch(t, []string{"parent", "child"}, names)
+	})
+
+	t.Run("Grandchild", func(t *testing.T) {
+		app := fxtest.New(t, fx.Supply(&A{Name: "root"}))
+		child := app.NewChild()
+		require.NoError(t, child.Err())
+
+		var a *A
+		grandchild := child.NewChild(fx.Populate(&a))
+		require.NoError(t, grandchild.Err())
+		assert.Equal(t, "root", a.Name)
+	})
+
+	t.Run("ParentErrorsAreReported", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Provide(func() (*A, error) {
+			return nil, errors.New("great sadness")
+		}), fx.Invoke(func(*A) {}))
+		require.Error(t, app.Err())
+
+		child := app.NewChild()
+		err := child.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot build a child of an application that failed to build")
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+
+	t.Run("InheritedErrors", func(t *testing.T) {
+		app := fxtest.New(t, fx.Provide(func() (*A, error) {
+			return nil, errors.New("great sadness")
+		}))
+
+		child := app.NewChild(fx.NopLogger, fx.Invoke(func(*A) {}))
+		err := child.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Contains(t, err.Error(), "TestNewChild")
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+	})
+}
+
+func TestNewChildLifecycle(t *testing.T) {
+	hooks := func(calls *[]string, name string) fx.Option {
+		return fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					*calls = append(*calls, "start "+name)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					*calls = append(*calls, "stop "+name)
+					return nil
+				},
+			})
+		})
+	}
+
+	t.Run("NestedInParent", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t, hooks(&calls, "parent"))
+		child := app.NewChild(hooks(&calls, "child"))
+		require.NoError(t, child.Err())
+
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{
+			"start parent", "start child", "stop child", "stop parent",
+		}, calls)
+	})
+
+	t.Run("StartedIndependently", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t, hooks(&calls, "parent"))
+		app.RequireStart()
+
+		child := app.NewChild(hooks(&calls, "child"))
+		require.NoError(t, child.Start(context.Background()))
+		require.NoError(t, child.Start(context.Background()), "starting twice must do nothing")
+
+		app.RequireStop()
+		assert.Equal(t, []string{
+			"start parent", "start child", "stop child", "stop parent",
+		}, calls)
+	})
+
+	t.Run("StoppedIndependently", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t, hooks(&calls, "parent"))
+		child := app.NewChild(hooks(&calls, "child"))
+		app.RequireStart()
+
+		require.NoError(t, child.Stop(context.Background()))
+		app.RequireStop()
+		assert.Equal(t, []string{
+			"start parent", "start child", "stop child", "stop parent",
+		}, calls)
+	})
+
+	t.Run("ChildStartFailure", func(t *testing.T) {
+		var calls []string
+		app := fx.New(fx.NopLogger, hooks(&calls, "parent"))
+		child := app.NewChild(fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error { return errors.New("great sadness") },
+			})
+		}))
+		require.NoError(t, child.Err())
+
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Equal(t, []string{"start parent", "stop parent"}, calls)
+	})
+}
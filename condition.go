@@ -0,0 +1,314 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+)
+
+var _typeOfBool = reflect.TypeOf(true)
+
+// If applies the given options only if cond is true. This allows
+// applications to switch options declaratively rather than branching in
+// code. For example,
+//
+//  fx.New(
+//    fx.If(env == "dev", fx.Provide(NewDevLogger)),
+//    fx.If(env != "dev", fx.Provide(NewProdLogger)),
+//    ...
+//  )
+//
+// Unlike options guarded by When, options passed to If may include any
+// option, including WithLogger.
+//
+// Fx emits an fxevent.Condition event reporting whether the options were
+// applied.
+func If(cond bool, opts ...Option) Option {
+	return ifOption{
+		Cond:    cond,
+		Options: opts,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
+}
+
+type ifOption struct {
+	Cond    bool
+	Options []Option
+	Stack   fxreflect.Stack
+}
+
+func (o ifOption) apply(app *App) {
+	app.ifs = append(app.ifs, &fxevent.Condition{
+		Options: optionGroup{Options: o.Options}.String(),
+		Result:  o.Cond,
+	})
+	if o.Cond {
+		for _, opt := range o.Options {
+			opt.apply(app)
+		}
+	}
+}
+
+// visit reports the guarded options regardless of cond.
+func (o ifOption) visit(v *optionVisitor) {
+	v.report(o, "fx.If", o.Stack, o.Cond)
+	v.nest(o, o.Options...)
+}
+
+func (o ifOption) String() string {
+	return fmt.Sprintf("fx.If(%v, %v)", o.Cond, optionGroup{Options: o.Options})
+}
+
+// When applies the given options only if condition returns true. condition
+// is a function whose arguments are resolved from the container, similar to
+// functions passed to Invoke, and which returns a bool and optionally an
+// error. This allows options to be selected based on values from the
+// application itself, such as its configuration. For example,
+//
+//  fx.New(
+//    fx.Provide(NewConfig),
+//    fx.When(func(cfg *Config) bool {
+//      return cfg.Tracing.Enabled
+//    }, tracing.Module),
+//    ...
+//  )
+//
+// Conditions are evaluated in order after all other constructors have been
+// provided, but before any functions are invoked. Functions invoked by the
+// guarded options run in the position of the When option relative to other
+// invocations. Conditions cannot depend on values provided by the options
+// they guard, values registered with fx.Default, or Graph.
+//
+// Options that must take effect before the container is built, like
+// WithLogger, have no effect when guarded by When.
+//
+// Every time a condition is evaluated, Fx emits an fxevent.Condition event
+// reporting the result. If a condition fails, New fails with an InvokeError
+// that names the condition and where it was passed to When.
+//
+// ValidateApp doesn't call conditions. It validates the application as if
+// none of them held, and again for each When as if only its condition held.
+func When(condition interface{}, opts ...Option) Option {
+	stack := fxreflect.CallerStack(1, 0)
+
+	ft := reflect.TypeOf(condition)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return Error(fmt.Errorf(
+			"fx.When expected a function, got %T from:\n%+v", condition, stack))
+	}
+
+	switch {
+	case ft.NumOut() == 1 && ft.Out(0) == _typeOfBool:
+	case ft.NumOut() == 2 && ft.Out(0) == _typeOfBool && ft.Out(1) == _typeOfError:
+	default:
+		return Error(fmt.Errorf(
+			"fx.When expected a function returning bool or (bool, error), got %v from:\n%+v",
+			ft, stack))
+	}
+
+	return whenOption{
+		Condition: condition,
+		Options:   opts,
+		Stack:     stack,
+	}
+}
+
+type whenOption struct {
+	Condition interface{}
+	Options   []Option
+	Stack     fxreflect.Stack
+}
+
+func (o whenOption) apply(app *App) {
+	// Leave a placeholder in the list of invokes. This is replaced by the
+	// invokes of the guarded options once the condition is evaluated.
+	app.invokes = append(app.invokes, invoke{
+		Stack: o.Stack,
+		When:  &o,
+	})
+}
+
+// visit reports the guarded options, which are never evaluated while
+// visiting.
+func (o whenOption) visit(v *optionVisitor) {
+	v.report(o, "fx.When", o.Stack, o.Condition)
+	v.nest(o, o.Options...)
+}
+
+func (o whenOption) String() string {
+	return fmt.Sprintf("fx.When(%v, %v)", fxreflect.FuncName(o.Condition), optionGroup{Options: o.Options})
+}
+
+// Evaluate invokes the condition and reports its result.
+func (o *whenOption) Evaluate(app *App) (bool, error) {
+	ft := reflect.TypeOf(o.Condition)
+	in := make([]reflect.Type, ft.NumIn())
+	for i := range in {
+		in[i] = ft.In(i)
+	}
+
+	// Build a function that looks like:
+	//
+	// func(args...) error {
+	//   result, err = condition(args...)
+	//   return err
+	// }
+	var result bool
+	fnType := reflect.FuncOf(in, []reflect.Type{_typeOfError}, ft.IsVariadic())
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		var out []reflect.Value
+		if ft.IsVariadic() {
+			out = reflect.ValueOf(o.Condition).CallSlice(args)
+		} else {
+			out = reflect.ValueOf(o.Condition).Call(args)
+		}
+
+		result = out[0].Bool()
+		if len(out) > 1 {
+			return out[1:]
+		}
+		return []reflect.Value{reflect.Zero(_typeOfError)}
+	})
+
+	target, err := envFunc(fn.Interface())
+	if err != nil {
+		return false, err
+	}
+
+	node := &graph.Node{
+		Func:   o.Condition,
+		Stack:  o.Stack,
+		Params: fxreflect.InspectSignature(target).Params,
+	}
+	app.graph.AddInvoke(node)
+	target = app.invokeGroupFunc(app.lazyFunc(target))
+	if err := app.container.Invoke(target); err != nil {
+		return false, app.containerError(node, err)
+	}
+	return result, nil
+}
+
+// evaluateConditions evaluates the conditions of all fx.When options in
+// order, applying the guarded options when they hold.
+func (app *App) evaluateConditions() {
+	for idx := 0; idx < len(app.invokes) && app.err == nil && app.whenErr == nil; idx++ {
+		when := app.invokes[idx].When
+		if when == nil {
+			continue
+		}
+
+		// Remove the placeholder and splice in the invokes of the
+		// guarded options in its place.
+		rest := append([]invoke(nil), app.invokes[idx+1:]...)
+		app.invokes = app.invokes[:idx]
+
+		app.numWhens++
+		ok, err := when.Evaluate(app)
+		if err == nil && app.validate {
+			// Conditions aren't called while validating. The guarded
+			// options are validated separately.
+			ok = app.numWhens == app.assumeWhen
+			if app.assumeWhen == 0 {
+				err = app.validateWhen(app.numWhens)
+			}
+		}
+		app.log.LogEvent(&fxevent.Condition{
+			Function: when.Condition,
+			Options:  optionGroup{Options: when.Options}.String(),
+			Result:   ok,
+			Err:      err,
+		})
+		if err != nil {
+			app.whenErr = &InvokeError{
+				Function: when.Condition,
+				Stack:    when.Stack,
+				Err:      err,
+				option:   "fx.When",
+			}
+			return
+		}
+
+		if ok {
+			numProvides := len(app.provides)
+			for _, opt := range when.Options {
+				opt.apply(app)
+			}
+			app.logIfs()
+			for _, p := range app.provides[numProvides:] {
+				app.provide(p)
+			}
+		}
+		app.invokes = append(app.invokes, rest...)
+
+		// Revisit this position since the guarded options may have
+		// placed another When here.
+		idx--
+	}
+}
+
+// validateWhen validates the application as if the condition of its n-th
+// fx.When held, in a separate application built from the same options.
+// The application being validated goes on as if it didn't hold, so that
+// options guarded by conditions that exclude each other are validated
+// separately.
+func (app *App) validateWhen(n int) error {
+	opts := append(append([]Option(nil), app.options...), assumeWhenOption{N: n})
+	if err := New(opts...).Err(); err != nil {
+		return fmt.Errorf("options applied if the condition holds are invalid: %w", err)
+	}
+	return nil
+}
+
+// assumeWhenOption makes an application that's being validated apply the
+// options guarded by its n-th fx.When. The application only checks those
+// options for another one, so it logs nothing and calls no error handlers.
+type assumeWhenOption struct {
+	N int
+}
+
+func (o assumeWhenOption) apply(app *App) {
+	app.validate = true
+	app.assumeWhen = o.N
+	app.log = fxevent.NopLogger
+	app.logConstructor = nil
+	app.errorHooks = nil
+}
+
+// assumeWhenOption is only used by validateWhen, so there's nothing to
+// report.
+func (assumeWhenOption) visit(*optionVisitor) {}
+
+func (o assumeWhenOption) String() string {
+	return fmt.Sprintf("fx.assumeWhen(%v)", o.N)
+}
+
+// logIfs logs the results of the fx.If options applied since the last call.
+func (app *App) logIfs() {
+	for _, e := range app.ifs {
+		app.log.LogEvent(e)
+	}
+	app.ifs = nil
+}
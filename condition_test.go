@@ -0,0 +1,288 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+func TestIf(t *testing.T) {
+	type A struct{ Env string }
+
+	newApp := func(dev bool) *A {
+		var a *A
+		app := fxtest.New(t,
+			fx.If(dev, fx.Provide(func() *A { return &A{Env: "dev"} })),
+			fx.If(!dev, fx.Provide(func() *A { return &A{Env: "prod"} })),
+			fx.Populate(&a),
+		)
+		defer app.RequireStart().RequireStop()
+		return a
+	}
+
+	assert.Equal(t, "dev", newApp(true).Env)
+	assert.Equal(t, "prod", newApp(false).Env)
+
+	t.Run("Events", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.If(true, fx.Provide(func() *A { return &A{} })),
+			fx.If(false, fx.Invoke(func() { t.Fatal("must not be invoked") })),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var conds []*fxevent.Condition
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Condition); ok {
+				conds = append(conds, e)
+			}
+		}
+		require.Len(t, conds, 2)
+
+		assert.Nil(t, conds[0].Function)
+		assert.True(t, conds[0].Result)
+		assert.Contains(t, conds[0].Options, "fx.Provide(")
+
+		assert.Nil(t, conds[1].Function)
+		assert.False(t, conds[1].Result)
+		assert.Contains(t, conds[1].Options, "fx.Invoke(")
+	})
+}
+
+func TestWhen(t *testing.T) {
+	type Config struct{ Tracing bool }
+	type Tracer struct{}
+
+	t.Run("Applied", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+
+		var calls []string
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Supply(&Config{Tracing: true}),
+			fx.Invoke(func() { calls = append(calls, "first") }),
+			fx.When(func(cfg *Config) bool { return cfg.Tracing },
+				fx.Provide(func() *Tracer { return &Tracer{} }),
+				fx.Invoke(func(*Tracer) { calls = append(calls, "tracer") }),
+			),
+			fx.Invoke(func() { calls = append(calls, "last") }),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"first", "tracer", "last"}, calls)
+
+		var found bool
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Condition); ok {
+				found = true
+				assert.True(t, e.Result)
+				assert.NoError(t, e.Err)
+				assert.Contains(t, e.Options, "fx.Provide(")
+			}
+		}
+		assert.True(t, found, "expected a Condition event")
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		var tracer *Tracer
+		app := fxtest.New(t,
+			fx.Supply(&Config{Tracing: false}),
+			fx.When(func(cfg *Config) bool { return cfg.Tracing },
+				fx.Provide(func() *Tracer { return &Tracer{} }),
+				fx.Invoke(func(*Tracer) { t.Fatal("must not be called") }),
+			),
+			fx.Invoke(func(p struct {
+				fx.In
+
+				Tracer *Tracer `optional:"true"`
+			}) {
+				tracer = p.Tracer
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Nil(t, tracer)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.When(func() bool { return true },
+				fx.Invoke(func() { calls = append(calls, "outer") }),
+				fx.When(func() bool { return true },
+					fx.Invoke(func() { calls = append(calls, "inner") }),
+				),
+			),
+			fx.Invoke(func() { calls = append(calls, "last") }),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"outer", "inner", "last"}, calls)
+	})
+
+	t.Run("NestedIf", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+
+		var tracer *Tracer
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.When(func() bool { return true },
+				fx.If(true, fx.Provide(func() *Tracer { return &Tracer{} })),
+				fx.If(false, fx.Invoke(func() { t.Fatal("must not be invoked") })),
+			),
+			fx.Populate(&tracer),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.NotNil(t, tracer)
+
+		var conds []*fxevent.Condition
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Condition); ok {
+				conds = append(conds, e)
+			}
+		}
+		require.Len(t, conds, 3)
+
+		assert.NotNil(t, conds[0].Function, "expected the fx.When event first")
+		assert.True(t, conds[0].Result)
+
+		assert.Nil(t, conds[1].Function)
+		assert.True(t, conds[1].Result)
+		assert.Contains(t, conds[1].Options, "fx.Provide(")
+
+		assert.Nil(t, conds[2].Function)
+		assert.False(t, conds[2].Result)
+		assert.Contains(t, conds[2].Options, "fx.Invoke(")
+	})
+
+	t.Run("ConditionFails", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := NewForTest(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.When(func() (bool, error) { return false, errors.New("great sadness") }),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fx.When(go.uber.org/fx_test.TestWhen")
+		assert.Contains(t, err.Error(), "condition_test.go")
+		assert.Contains(t, err.Error(), "Failed: great sadness")
+
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError")
+		assert.Contains(t, fxreflect.FuncName(invokeErr.Function), "TestWhen")
+		assert.Contains(t, invokeErr.Stack.String(), "condition_test.go")
+		assert.EqualError(t, invokeErr.Err, "great sadness")
+
+		assert.NotContains(t, spy.EventTypes(), "ProvideError",
+			"failed conditions must not be reported as provide errors")
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.When(func(*Config) bool { return true }),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing dependencies for function")
+		assert.Contains(t, err.Error(), "TestWhen")
+		assert.Contains(t, err.Error(), "condition_test.go")
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+	})
+
+	t.Run("InvalidCondition", func(t *testing.T) {
+		app := NewForTest(t, fx.When(func() string { return "yes" }))
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			"fx.When expected a function returning bool or (bool, error), got func() string")
+	})
+
+	t.Run("NotAFunction", func(t *testing.T) {
+		app := NewForTest(t, fx.When(true))
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fx.When expected a function, got bool")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		type Logger struct{ Name string }
+
+		t.Run("GuardedOptionsAreValidated", func(t *testing.T) {
+			err := fx.ValidateApp(
+				fx.Supply(&Config{}),
+				fx.When(func(*Config) bool { return false },
+					fx.Invoke(func(*Tracer) {}),
+				),
+			)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "fx.When(go.uber.org/fx_test.TestWhen")
+			assert.Contains(t, err.Error(), "condition_test.go")
+			assert.Contains(t, err.Error(), "options applied if the condition holds are invalid")
+			assert.Contains(t, err.Error(), "missing type: *fx_test.Tracer")
+		})
+
+		t.Run("ConditionsAreNotCalled", func(t *testing.T) {
+			err := fx.ValidateApp(
+				fx.Supply(&Config{}),
+				fx.When(func(*Config) bool {
+					t.Error("condition must not be called while validating")
+					return true
+				}, fx.Provide(func() *Tracer { return &Tracer{} })),
+				fx.Invoke(func(*Config) {}),
+			)
+			assert.NoError(t, err)
+		})
+
+		t.Run("ExclusiveConditions", func(t *testing.T) {
+			err := fx.ValidateApp(
+				fx.Supply(&Config{}),
+				fx.When(func(cfg *Config) bool { return cfg.Tracing },
+					fx.Provide(func() *Logger { return &Logger{Name: "tracing"} }),
+				),
+				fx.When(func(cfg *Config) bool { return !cfg.Tracing },
+					fx.Provide(func() *Logger { return &Logger{Name: "plain"} }),
+					fx.Invoke(func(*Logger) {}),
+				),
+			)
+			assert.NoError(t, err)
+		})
+
+		t.Run("MissingConditionDependency", func(t *testing.T) {
+			err := fx.ValidateApp(
+				fx.When(func(*Config) bool { return true }),
+			)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "fx.When(go.uber.org/fx_test.TestWhen")
+			assert.Contains(t, err.Error(), "missing dependencies for function")
+		})
+	})
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/lifecycle"
+)
+
+// DebugHandler makes a *Debugger available in the container. Debugger is
+// an http.Handler that serves information about the running application,
+// so that it may be inspected in production. Mount it on a server of your
+// choice:
+//
+//  fx.New(
+//    fx.DebugHandler,
+//    fx.Invoke(func(mux *http.ServeMux, d *fx.Debugger) {
+//      mux.Handle("/debug/fx/", http.StripPrefix("/debug/fx", d))
+//    }),
+//  )
+//
+// Debugging information may include sensitive details about the
+// application, so it should not be exposed publicly.
+var DebugHandler Option = debugOption{}
+
+type debugOption struct{}
+
+func (debugOption) apply(app *App) {
+	if app.debugger != nil {
+		return
+	}
+
+	d := &Debugger{app: app}
+	app.debugger = d
+	app.provides = append(app.provides, provide{
+		Target:    func() *Debugger { return d },
+		IsBuiltin: true,
+	})
+}
+
+func (o debugOption) visit(v *optionVisitor) {
+	v.report(o, "fx.DebugHandler", nil)
+}
+
+func (debugOption) String() string {
+	return "fx.DebugHandler"
+}
+
+// _debugEventsLimit is the number of recent events kept by the Debugger.
+const _debugEventsLimit = 100
+
+// _debugRunsLimit is the number of recent constructor runs kept by the
+// Debugger. Constructors usually run once, while the application starts,
+// but request-scoped constructors run for every scope.
+const _debugRunsLimit = 1000
+
+// Debugger serves debugging information about an application over HTTP.
+// It's available in the container if the application was built with the
+// DebugHandler option. It serves the following paths.
+//
+//  /graph.dot     the dependency graph in the DOT language (see DotGraph)
+//  /graph.json    the dependency graph as JSON (see Graph)
+//  /types         types provided to the container, and their constructors
+//  /constructors  the most recent constructors that ran, and how long they took
+//  /hooks         lifecycle hooks that ran, and how long they took
+//  /events        the most recent events logged by Fx
+//
+// All paths except /graph.dot serve JSON.
+type Debugger struct {
+	app *App
+
+	mu     sync.Mutex
+	events []loggedEvent // up to _debugEventsLimit most recent events
+	runs   []debugRun    // up to _debugRunsLimit most recent constructor runs
+}
+
+var _ http.Handler = (*Debugger)(nil)
+
+// ServeHTTP serves the path requested by r.
+func (d *Debugger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The graph of the application doesn't change once its values are
+	// available, so it's read without holding containerMu.
+	switch r.URL.Path {
+	case "/graph.dot":
+		g, err := d.app.dotGraph()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		fmt.Fprint(w, g)
+	case "/graph.json":
+		d.serveJSON(w, d.app.structuredGraph())
+	case "/types":
+		d.serveJSON(w, d.types())
+	case "/constructors":
+		d.mu.Lock()
+		runs := append([]debugRun{}, d.runs...)
+		d.mu.Unlock()
+		d.serveJSON(w, runs)
+	case "/hooks":
+		d.serveJSON(w, d.hooks())
+	case "/events":
+		d.serveJSON(w, d.formatEvents())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *Debugger) serveJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// debugType is a type provided to the container.
+type debugType struct {
+	Type        string `json:"type"`
+	Constructor string `json:"constructor"`
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+}
+
+func (d *Debugger) types() []debugType {
+	types := []debugType{}
+	for _, n := range d.app.graph.Constructors {
+		for _, k := range n.Results {
+			t := debugType{
+				Type:        k.String(),
+				Constructor: fxreflect.FuncName(n.Func),
+			}
+			if f, ok := n.Stack.Caller(); ok {
+				t.File = f.File
+				t.Line = f.Line
+			}
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// debugRun is a constructor that ran.
+type debugRun struct {
+	Constructor string        `json:"constructor"`
+	Runtime     time.Duration `json:"runtime"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// debugHook is a lifecycle hook that ran.
+type debugHook struct {
+	Function string        `json:"function"`
+	Caller   string        `json:"caller"`
+	File     string        `json:"file,omitempty"`
+	Line     int           `json:"line,omitempty"`
+	Runtime  time.Duration `json:"runtime"`
+}
+
+type debugHooks struct {
+	OnStart []debugHook `json:"onStart"`
+	OnStop  []debugHook `json:"onStop"`
+}
+
+func (d *Debugger) hooks() debugHooks {
+	newHooks := func(records lifecycle.HookRecords) []debugHook {
+		hooks := make([]debugHook, len(records))
+		for i, r := range records {
+			hooks[i] = debugHook{
+				Function: fxreflect.FuncName(r.Func),
+				Caller:   r.CallerFrame.Function,
+				File:     r.CallerFrame.File,
+				Line:     r.CallerFrame.Line,
+				Runtime:  r.Runtime,
+			}
+		}
+		return hooks
+	}
+
+	return debugHooks{
+		OnStart: newHooks(d.app.lifecycle.startHookRecords()),
+		OnStop:  newHooks(d.app.lifecycle.stopHookRecords()),
+	}
+}
+
+// debugEvent is an event logged by Fx.
+type debugEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// loggedEvent is an event recorded by the Debugger, along with when it was
+// logged.
+type loggedEvent struct {
+	time  time.Time
+	event fxevent.Event
+}
+
+// formatEvents formats the recorded events the same way the console logger
+// would.
+func (d *Debugger) formatEvents() []debugEvent {
+	d.mu.Lock()
+	logged := append([]loggedEvent(nil), d.events...)
+	d.mu.Unlock()
+
+	var buf bytes.Buffer
+	logger := &fxevent.ConsoleLogger{W: &buf}
+	events := make([]debugEvent, len(logged))
+	for i, e := range logged {
+		buf.Reset()
+		logger.LogEvent(e.event)
+		events[i] = debugEvent{
+			Time:    e.time,
+			Type:    reflect.TypeOf(e.event).Elem().Name(),
+			Message: strings.TrimPrefix(strings.TrimSpace(buf.String()), "[Fx] "),
+		}
+	}
+	return events
+}
+
+// logEvent records an event, dropping the oldest recorded event or
+// constructor run if the limit has been reached.
+func (d *Debugger) logEvent(event fxevent.Event) {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := event.(*fxevent.Run); ok {
+		r := debugRun{
+			Constructor: fxreflect.FuncName(e.Constructor),
+			Runtime:     e.Runtime,
+		}
+		if e.Err != nil {
+			r.Error = e.Err.Error()
+		}
+		if len(d.runs) == _debugRunsLimit {
+			d.runs = append(d.runs[:0], d.runs[1:]...)
+		}
+		d.runs = append(d.runs, r)
+	}
+	if len(d.events) == _debugEventsLimit {
+		d.events = append(d.events[:0], d.events[1:]...)
+	}
+	d.events = append(d.events, loggedEvent{time: now, event: event})
+}
+
+// debugLogger records events with a Debugger before passing them on.
+type debugLogger struct {
+	debugger *Debugger
+	logger   fxevent.Logger
+}
+
+// withDebugger records events logged to the given logger with the
+// application's Debugger, if any.
+func (app *App) withDebugger(logger fxevent.Logger) fxevent.Logger {
+	if app.debugger == nil {
+		return logger
+	}
+	return debugLogger{debugger: app.debugger, logger: logger}
+}
+
+func (l debugLogger) LogEvent(event fxevent.Event) {
+	l.debugger.logEvent(event)
+	l.logger.LogEvent(event)
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+)
+
+func TestDebugHandler(t *testing.T) {
+	spy := new(fxlog.Spy)
+
+	var d *fx.Debugger
+	app := fxtest.New(t,
+		fx.DebugHandler,
+		fx.WithLogger(func() fxevent.Logger { return spy }),
+		fx.Provide(bytes.NewBufferString),
+		fx.Supply("hello"),
+		fx.Invoke(func(lc fx.Lifecycle, _ *bytes.Buffer) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error { return nil },
+			})
+		}),
+		fx.Populate(&d),
+	)
+	app.RequireStart().RequireStop()
+	require.NotNil(t, d)
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	t.Run("DotGraph", func(t *testing.T) {
+		w := get(t, "/graph.dot")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "digraph {")
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		w := get(t, "/graph.json")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var g fx.Graph
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &g))
+		assert.NotEmpty(t, g.Nodes)
+	})
+
+	t.Run("Types", func(t *testing.T) {
+		var types []struct {
+			Type        string
+			Constructor string
+			File        string
+		}
+		require.NoError(t, json.Unmarshal(get(t, "/types").Body.Bytes(), &types))
+
+		var found bool
+		for _, typ := range types {
+			if typ.Type == "*bytes.Buffer" {
+				found = true
+				assert.Equal(t, "bytes.NewBufferString()", typ.Constructor)
+				assert.Contains(t, typ.File, "debug_test.go")
+			}
+			if typ.Type == "*fx.Debugger" {
+				assert.Empty(t, typ.File, "the Debugger is provided by Fx itself")
+			}
+		}
+		assert.True(t, found, "*bytes.Buffer not found in %v", types)
+	})
+
+	t.Run("Constructors", func(t *testing.T) {
+		var runs []struct {
+			Constructor string
+			Runtime     time.Duration
+			Error       string
+		}
+		require.NoError(t, json.Unmarshal(get(t, "/constructors").Body.Bytes(), &runs))
+		require.Len(t, runs, 1)
+		assert.Equal(t, "bytes.NewBufferString()", runs[0].Constructor)
+		assert.Empty(t, runs[0].Error)
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		var hooks struct {
+			OnStart []struct {
+				Function string
+				Caller   string
+			}
+			OnStop []struct{}
+		}
+		require.NoError(t, json.Unmarshal(get(t, "/hooks").Body.Bytes(), &hooks))
+		require.Len(t, hooks.OnStart, 1)
+		assert.Contains(t, hooks.OnStart[0].Caller, "TestDebugHandler")
+		assert.Empty(t, hooks.OnStop)
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		var events []struct {
+			Type    string
+			Message string
+		}
+		require.NoError(t, json.Unmarshal(get(t, "/events").Body.Bytes(), &events))
+
+		types := make([]string, len(events))
+		for i, e := range events {
+			types[i] = e.Type
+		}
+		assert.Equal(t, spy.EventTypes(), types)
+		assert.Contains(t, events, struct {
+			Type    string
+			Message string
+		}{Type: "Supply", Message: "SUPPLY\tstring"})
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(t, "/unknown").Code)
+	})
+}
+
+func TestDebugHandlerEventLimit(t *testing.T) {
+	var d *fx.Debugger
+	opts := []fx.Option{fx.DebugHandler, fx.NopLogger, fx.Populate(&d)}
+	for i := 0; i < 150; i++ {
+		opts = append(opts, fx.Invoke(func() {}))
+	}
+	app := fxtest.New(t, opts...)
+	defer app.RequireStart().RequireStop()
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	var events []struct{ Type string }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+	assert.Len(t, events, 100)
+}
+
+func TestDebugHandlerConstructorLimit(t *testing.T) {
+	var d *fx.Debugger
+	opts := []fx.Option{fx.DebugHandler, fx.NopLogger, fx.Populate(&d)}
+	for i := 0; i < 1050; i++ {
+		i := i
+		opts = append(opts, fx.Provide(fx.Annotated{
+			Group:  "numbers",
+			Target: func() int { return i },
+		}))
+	}
+	opts = append(opts, fx.Invoke(func(struct {
+		fx.In
+
+		Numbers []int `group:"numbers"`
+	}) {
+	}))
+	app := fxtest.New(t, opts...)
+	defer app.RequireStart().RequireStop()
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest("GET", "/constructors", nil))
+
+	var runs []struct{ Constructor string }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	assert.Len(t, runs, 1000)
+}
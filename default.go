@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// Default registers fallback values for types that may not be provided by
+// any constructor in the application. A default value is added to the
+// container only if nothing else provides its type, so optional dependencies
+// on that type receive the default instead of the zero value.
+//
+// For example, given a library that optionally depends on a *Cache,
+//
+//  type Params struct {
+//  	fx.In
+//
+//  	Cache *Cache `optional:"true"`
+//  }
+//
+// the following makes Params.Cache a no-op cache unless the application
+// provides its own *Cache:
+//
+//  fx.Default(NopCache)
+//
+// Named defaults may be specified with fx.Annotated. Defaults cannot be
+// added to value groups.
+//
+//  fx.Default(fx.Annotated{Name: "ro", Target: NopCache})
+//
+// Default values aren't deduplicated like constructors, since they're
+// built anew by each call to Default. Instead, if more than one default is
+// registered for a type, as when a module is included twice, the first one
+// is used and the rest are ignored.
+//
+// Fx emits an fxevent.Default event for each default value added to the
+// container. This happens while the application is built, whether or not
+// any function goes on to consume the value.
+//
+// Default panics if a value (or annotation target) is an untyped nil or an
+// error.
+func Default(values ...interface{}) Option {
+	constructors := make([]interface{}, len(values)) // one function per value
+	types := make([]reflect.Type, len(values))
+	for i, value := range values {
+		switch value := value.(type) {
+		case Annotated:
+			if len(value.Group) > 0 {
+				return Error(fmt.Errorf(
+					"fx.Default cannot be used with value groups: received %v", value))
+			}
+
+			var typ reflect.Type
+			value.Target, typ = newValueConstructor("fx.Default", value.Target)
+			constructors[i] = value
+			types[i] = typ
+		default:
+			constructors[i], types[i] = newValueConstructor("fx.Default", value)
+		}
+	}
+
+	return defaultOption{
+		Targets: constructors,
+		Types:   types,
+		Values:  values,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
+}
+
+type defaultOption struct {
+	Targets []interface{}
+	Types   []reflect.Type // type of value produced by constructor[i]
+	Values  []interface{}  // values passed to fx.Default
+	Stack   fxreflect.Stack
+}
+
+func (o defaultOption) apply(app *App) {
+	for i, target := range o.Targets {
+		app.defaults = append(app.defaults, provide{
+			Target:     target,
+			Stack:      o.Stack,
+			IsDefault:  true,
+			SupplyType: o.Types[i],
+		})
+	}
+}
+
+func (o defaultOption) visit(v *optionVisitor) {
+	v.report(o, "fx.Default", o.Stack, o.Values...)
+}
+
+func (o defaultOption) String() string {
+	items := make([]string, 0, len(o.Targets))
+	for _, typ := range o.Types {
+		items = append(items, typ.String())
+	}
+	return fmt.Sprintf("fx.Default(%s)", strings.Join(items, ", "))
+}
+
+// provideDefaults provides the default values for which no other constructor
+// was provided.
+func (app *App) provideDefaults() {
+	for _, p := range app.defaults {
+		provided := false
+		for _, k := range resultKeys(p.Target) {
+			if len(app.graph.Providers(k)) > 0 {
+				provided = true
+			}
+		}
+
+		if !provided {
+			app.provide(p)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+)
+
+func TestDefault(t *testing.T) {
+	type A struct{ Value string }
+
+	type params struct {
+		fx.In
+
+		A *A `optional:"true"`
+	}
+
+	t.Run("Missing", func(t *testing.T) {
+		def := &A{Value: "default"}
+		spy := new(fxlog.Spy)
+
+		var got params
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Default(def),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Same(t, def, got.A)
+		assert.Contains(t, spy.EventTypes(), "Default")
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Default); ok {
+				assert.Equal(t, "*fx_test.A", e.TypeName)
+			}
+		}
+	})
+
+	t.Run("Provided", func(t *testing.T) {
+		provided := &A{Value: "provided"}
+		spy := new(fxlog.Spy)
+
+		var got params
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Default(&A{Value: "default"}),
+			fx.Provide(func() *A { return provided }),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Same(t, provided, got.A)
+		assert.NotContains(t, spy.EventTypes(), "Default")
+	})
+
+	t.Run("Named", func(t *testing.T) {
+		def := &A{Value: "default"}
+		provided := &A{Value: "provided"}
+
+		var got struct {
+			fx.In
+
+			RO *A `name:"ro" optional:"true"`
+			RW *A `name:"rw"`
+		}
+		app := fxtest.New(t,
+			fx.Default(
+				fx.Annotated{Name: "ro", Target: def},
+				fx.Annotated{Name: "rw", Target: &A{Value: "default"}},
+			),
+			fx.Provide(fx.Annotated{
+				Name:   "rw",
+				Target: func() *A { return provided },
+			}),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Same(t, def, got.RO)
+		assert.Same(t, provided, got.RW)
+	})
+
+	t.Run("FirstDefaultWins", func(t *testing.T) {
+		first, second := &A{Value: "first"}, &A{Value: "second"}
+
+		var got *A
+		app := fxtest.New(t,
+			fx.Default(first),
+			fx.Default(second),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Same(t, first, got)
+	})
+
+	t.Run("Group", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Default(fx.Annotated{Group: "as", Target: &A{}}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fx.Default cannot be used with value groups")
+	})
+
+	t.Run("InvalidArgument", func(t *testing.T) {
+		require.PanicsWithValue(t, "untyped nil passed to fx.Default", func() {
+			fx.Default(nil)
+		})
+	})
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+)
+
+// Eager calls all constructors provided to the application during New,
+// rather than only when their results are first needed. This surfaces
+// errors of rarely used constructors when the application is built,
+// rather than at runtime.
+//
+// Constructors are called in dependency order before any function is
+// invoked. If a constructor fails, New fails with a ProvideError that
+// reports where the constructor was provided from.
+//
+// To call only some constructors eagerly, set the Eager field of
+// fx.Annotated instead.
+func Eager() Option {
+	return eagerOption{Stack: fxreflect.CallerStack(1, 0)}
+}
+
+type eagerOption struct {
+	Stack fxreflect.Stack
+}
+
+func (eagerOption) apply(app *App) {
+	app.eager = true
+}
+
+func (o eagerOption) visit(v *optionVisitor) {
+	v.report(o, "fx.Eager", o.Stack)
+}
+
+func (eagerOption) String() string {
+	return "fx.Eager()"
+}
+
+// constructEager calls constructors that must be called eagerly, in
+// dependency order.
+func (app *App) constructEager() error {
+	if !app.eager && len(app.eagerNodes) == 0 {
+		return nil
+	}
+
+	for _, n := range app.graph.Sorted() {
+		_, ok := app.eagerNodes[n]
+		if !ok && (!app.eager || n.Builtin) {
+			continue
+		}
+
+		if err := app.container.Invoke(newConsumer(n.Results, nil)); err != nil {
+			return &ProvideError{
+				Constructor: n.Func,
+				Stack:       n.Stack,
+				Err:         app.containerError(consumerNode(n.Results), err),
+			}
+		}
+	}
+	return nil
+}
+
+// newConsumer builds a function that requests the values with the given
+// keys from the container, and passes them to fn. fn may be nil. Values of
+// groups are passed sorted by their order. The function looks like:
+//
+//  func(struct {
+//    fx.In
+//
+//    Field0 T0 `name:".."`
+//    Field1 []T1 `group:".."`
+//    [...]
+//  }) {}
+func newConsumer(keys []fxreflect.Key, fn func([]reflect.Value)) interface{} {
+	fields := make([]reflect.StructField, 0, len(keys)+1)
+	fields = append(fields, reflect.StructField{
+		Name:      "In",
+		Type:      reflect.TypeOf(In{}),
+		Anonymous: true,
+	})
+	for i, k := range keys {
+		f := reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: k.Type,
+		}
+		switch {
+		case len(k.Name) > 0:
+			f.Tag = reflect.StructTag(fmt.Sprintf(`name:"%v"`, k.Name))
+		case len(k.Group) > 0:
+			f.Type = reflect.SliceOf(k.Type)
+			f.Tag = reflect.StructTag(fmt.Sprintf(`group:"%v"`, k.Group))
+		}
+		fields = append(fields, f)
+	}
+
+	// Values of groups that were given an order or a key are requested
+	// from the hidden groups of their groups as well.
+	hidden := make(map[int]int)
+	for i, k := range keys {
+		if len(k.Group) > 0 {
+			hidden[i] = len(fields)
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("Grouped%d", i),
+				Type: _typeOfGroupValues,
+				Tag:  reflect.StructTag(fmt.Sprintf(`group:"%v"`, hiddenGroup(k.Group))),
+			})
+		}
+	}
+
+	fnType := reflect.FuncOf([]reflect.Type{reflect.StructOf(fields)}, nil, false /* variadic */)
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		if fn != nil {
+			values := make([]reflect.Value, len(keys))
+			for i := range values {
+				values[i] = args[0].Field(i + 1)
+				if h, ok := hidden[i]; ok {
+					hidden := args[0].Field(h).Interface().([]groupValue)
+					values[i] = sortGroup(values[i].Type(), values[i], hidden)
+				}
+			}
+			fn(values)
+		}
+		return nil
+	}).Interface()
+}
+
+// consumerNode describes a function built by newConsumer for the given
+// keys to containerError. The function has no Func since it isn't passed
+// to Fx by the user.
+func consumerNode(keys []fxreflect.Key) *graph.Node {
+	params := make([]fxreflect.Param, len(keys))
+	for i, k := range keys {
+		params[i] = fxreflect.Param{Key: k}
+	}
+	return &graph.Node{Params: params}
+}
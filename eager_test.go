@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestEager(t *testing.T) {
+	type A struct{}
+	type B struct{}
+	type C struct{}
+
+	t.Run("CallsUnusedConstructors", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.Provide(
+				func(*B) *C { calls = append(calls, "C"); return &C{} },
+				func() *A { calls = append(calls, "A"); return &A{} },
+				func(*A) *B { calls = append(calls, "B"); return &B{} },
+			),
+			fx.Eager(),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"A", "B", "C"}, calls)
+	})
+
+	t.Run("NamesAndGroups", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Name:   "foo",
+					Target: func() *A { calls = append(calls, "named"); return &A{} },
+				},
+				fx.Annotated{
+					Group:  "bar",
+					Target: func() *A { calls = append(calls, "grouped"); return &A{} },
+				},
+			),
+			fx.Eager(),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"named", "grouped"}, calls)
+	})
+
+	t.Run("Annotated", func(t *testing.T) {
+		var calls []string
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Eager:  true,
+					Target: func() *A { calls = append(calls, "A"); return &A{} },
+				},
+				func() *B { calls = append(calls, "B"); return &B{} },
+			),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"A"}, calls)
+	})
+
+	t.Run("ConstructorFails", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(func() (*A, error) { return nil, errors.New("great sadness") }),
+			fx.Eager(),
+			fx.Invoke(func() { t.Fatal("must not be called") }),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		var perr *fx.ProvideError
+		require.True(t, errors.As(err, &perr), "expected a ProvideError")
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Contains(t, err.Error(), "eager_test.go")
+	})
+}
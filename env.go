@@ -0,0 +1,350 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/dig"
+	"go.uber.org/multierr"
+)
+
+var (
+	_typeOfError    = reflect.TypeOf((*error)(nil)).Elem()
+	_typeOfDuration = reflect.TypeOf(time.Duration(0))
+)
+
+// envField is a field of a parameter struct that is read from the
+// environment instead of the container.
+type envField struct {
+	Index      int    // index of the field in the parameter struct
+	Name       string // name of the field
+	Var        string // name of the environment variable
+	Default    string // value used if the variable is unset
+	HasDefault bool   // whether Default was specified
+	Required   bool   // whether the variable must be set
+}
+
+// envParam is a parameter struct with one or more env fields.
+type envParam struct {
+	// Original parameter struct type expected by the function.
+	Type reflect.Type
+
+	// Parameter struct type presented to dig. This holds all fields of the
+	// original struct except the env fields.
+	DigType reflect.Type
+
+	// Index of each non-env field of Type in DigType. -1 for env fields.
+	DigFields []int
+
+	Env []envField
+}
+
+// Build constructs the original parameter struct from a value of DigType,
+// reading env fields from the environment. Missing variables are reported
+// together in a single error.
+func (p *envParam) Build(v reflect.Value) (reflect.Value, error) {
+	result := reflect.New(p.Type).Elem()
+	for i, j := range p.DigFields {
+		if j >= 0 {
+			result.Field(i).Set(v.Field(j))
+		}
+	}
+
+	var (
+		missing []string
+		errs    error
+	)
+	for _, f := range p.Env {
+		s, ok := os.LookupEnv(f.Var)
+		if !ok {
+			if !f.HasDefault {
+				if f.Required {
+					missing = append(missing, f.Var)
+				}
+				continue
+			}
+			s = f.Default
+		}
+
+		field := result.Field(f.Index)
+		if err := parseEnv(field, s); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf(
+				"could not parse environment variable %v for field %v: %v", f.Var, f.Name, err))
+		}
+	}
+
+	if len(missing) > 0 {
+		errs = multierr.Append(fmt.Errorf(
+			"missing required environment variables: %v", strings.Join(missing, ", ")), errs)
+	}
+	return result, errs
+}
+
+// newEnvParam inspects the given parameter type and returns an envParam if
+// it's a parameter struct with env fields, or nil otherwise.
+func newEnvParam(t reflect.Type) (*envParam, error) {
+	if t.Kind() != reflect.Struct || !dig.IsIn(t) {
+		return nil, nil
+	}
+
+	p := envParam{
+		Type:      t,
+		DigFields: make([]int, t.NumField()),
+	}
+	ignoreUnexported, err := isIgnoreUnexported(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		fields     = make([]reflect.StructField, 0, t.NumField())
+		unexported []reflect.StructField
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		p.DigFields[i] = -1
+
+		name, ok := f.Tag.Lookup("env")
+		if !ok {
+			// reflect.StructOf doesn't support unexported fields, so
+			// they can't be carried over. dig never fills them, but
+			// rejects them unless the struct opts out, so they're
+			// rejected below the same way.
+			if f.PkgPath != "" {
+				if !ignoreUnexported {
+					unexported = append(unexported, f)
+				}
+				continue
+			}
+			p.DigFields[i] = len(fields)
+			fields = append(fields, f)
+			continue
+		}
+
+		switch {
+		case f.PkgPath != "":
+			return nil, fmt.Errorf("env field %v must be exported", f.Name)
+		case len(name) == 0:
+			return nil, fmt.Errorf("env field %v must specify a variable name", f.Name)
+		case f.Tag.Get("name") != "" || f.Tag.Get("group") != "":
+			return nil, fmt.Errorf("env field %v cannot specify a name or group", f.Name)
+		case !canParseEnv(f.Type):
+			return nil, fmt.Errorf("env field %v has unsupported type %v", f.Name, f.Type)
+		}
+
+		optional, _ := strconv.ParseBool(f.Tag.Get("optional"))
+		def, hasDefault := f.Tag.Lookup("default")
+		p.Env = append(p.Env, envField{
+			Index:      i,
+			Name:       f.Name,
+			Var:        name,
+			Default:    def,
+			HasDefault: hasDefault,
+			Required:   !optional,
+		})
+	}
+
+	if len(p.Env) == 0 {
+		return nil, nil
+	}
+
+	if len(unexported) > 0 {
+		f := unexported[0]
+		return nil, fmt.Errorf(
+			"unexported fields not allowed in fx.In, did you mean to export %q (%v)?",
+			f.Name, f.Type)
+	}
+
+	p.DigType = reflect.StructOf(fields)
+	return &p, nil
+}
+
+// isIgnoreUnexported reports whether the given parameter struct embeds
+// fx.In with the ignore-unexported tag, which lets it have unexported
+// fields.
+func isIgnoreUnexported(t reflect.Type) (bool, error) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous || f.Type != _typeOfIn {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("ignore-unexported")
+		if !ok {
+			return false, nil
+		}
+		ignore, err := strconv.ParseBool(tag)
+		if err != nil {
+			return false, fmt.Errorf(
+				"invalid value %q for %q tag on field %v: %v", tag, "ignore-unexported", f.Name, err)
+		}
+		return ignore, nil
+	}
+	return false, nil
+}
+
+// envFunc returns a function with the same behavior as fn, except that
+// fields of its parameter structs tagged with `env:".."` are read from the
+// environment when the function is called rather than requested from the
+// container.
+//
+// If fn does not return an error, the returned function will, so that it can
+// report missing or malformed variables. fn is returned as-is if none of its
+// parameters have env fields.
+func envFunc(fn interface{}) (interface{}, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fn, nil
+	}
+	ft := fv.Type()
+
+	var (
+		params   = make([]*envParam, ft.NumIn())
+		in       = make([]reflect.Type, ft.NumIn())
+		hasParam bool
+	)
+	for i := 0; i < ft.NumIn(); i++ {
+		p, err := newEnvParam(ft.In(i))
+		if err != nil {
+			return nil, err
+		}
+
+		in[i] = ft.In(i)
+		if p != nil {
+			params[i] = p
+			in[i] = p.DigType
+			hasParam = true
+		}
+	}
+	if !hasParam {
+		return fn, nil
+	}
+
+	out := make([]reflect.Type, ft.NumOut())
+	for i := range out {
+		out[i] = ft.Out(i)
+	}
+	returnsErr := len(out) > 0 && out[len(out)-1] == _typeOfError
+	if !returnsErr {
+		out = append(out, _typeOfError)
+	}
+
+	newFt := reflect.FuncOf(in, out, ft.IsVariadic())
+	return reflect.MakeFunc(newFt, func(args []reflect.Value) []reflect.Value {
+		var errs error
+		for i, p := range params {
+			if p == nil {
+				continue
+			}
+
+			v, err := p.Build(args[i])
+			errs = multierr.Append(errs, err)
+			args[i] = v
+		}
+
+		if errs != nil {
+			results := make([]reflect.Value, len(out))
+			for i, t := range out {
+				results[i] = reflect.Zero(t)
+			}
+			results[len(results)-1] = reflect.ValueOf(&errs).Elem()
+			return results
+		}
+
+		var results []reflect.Value
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+		if !returnsErr {
+			results = append(results, reflect.Zero(_typeOfError))
+		}
+		return results
+	}).Interface(), nil
+}
+
+func canParseEnv(t reflect.Type) bool {
+	if t == _typeOfDuration {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseEnv parses s into v based on the type of v.
+func parseEnv(v reflect.Value, s string) error {
+	if v.Type() == _typeOfDuration {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.New("unsupported type")
+	}
+	return nil
+}
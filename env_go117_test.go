@@ -0,0 +1,126 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.17
+// +build go1.17
+
+package fx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+// Tests that set environment variables use testing.T.Setenv, which was added
+// in Go 1.17.
+func TestEnvVariables(t *testing.T) {
+	type A struct{}
+
+	t.Run("Constructor", func(t *testing.T) {
+		t.Setenv("FX_TEST_PORT", "9090")
+		t.Setenv("FX_TEST_TIMEOUT", "3s")
+		t.Setenv("FX_TEST_DEBUG", "true")
+
+		type params struct {
+			fx.In
+
+			A       *A
+			Port    int           `env:"FX_TEST_PORT"`
+			Timeout time.Duration `env:"FX_TEST_TIMEOUT"`
+			Debug   bool          `env:"FX_TEST_DEBUG"`
+			Host    string        `env:"FX_TEST_HOST" default:"localhost"`
+			Ratio   float64       `env:"FX_TEST_RATIO" optional:"true"`
+		}
+
+		var got params
+		app := fxtest.New(t,
+			fx.Provide(
+				func() *A { return &A{} },
+				func(p params) string {
+					got = p
+					return "built"
+				},
+			),
+			fx.Invoke(func(string) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.NotNil(t, got.A)
+		assert.Equal(t, 9090, got.Port)
+		assert.Equal(t, 3*time.Second, got.Timeout)
+		assert.True(t, got.Debug)
+		assert.Equal(t, "localhost", got.Host)
+		assert.Zero(t, got.Ratio)
+	})
+
+	t.Run("Invoke", func(t *testing.T) {
+		t.Setenv("FX_TEST_NAME", "foo")
+
+		var name string
+		app := fxtest.New(t,
+			fx.Invoke(func(p struct {
+				fx.In
+
+				Name string `env:"FX_TEST_NAME"`
+			}) {
+				name = p.Name
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, "foo", name)
+	})
+
+	t.Run("Extract", func(t *testing.T) {
+		t.Setenv("FX_TEST_COUNT", "42")
+
+		var target struct {
+			Count uint `env:"FX_TEST_COUNT"`
+		}
+		app := fxtest.New(t, fx.Extract(&target))
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, uint(42), target.Count)
+	})
+
+	t.Run("InvalidValue", func(t *testing.T) {
+		t.Setenv("FX_TEST_BAD_PORT", "eighty")
+
+		app := NewForTest(t,
+			fx.Invoke(func(struct {
+				fx.In
+
+				Port int `env:"FX_TEST_BAD_PORT"`
+			}) error {
+				t.Fatal("function must not be called")
+				return nil
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			"could not parse environment variable FX_TEST_BAD_PORT for field Port")
+	})
+}
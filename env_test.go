@@ -0,0 +1,163 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestEnv(t *testing.T) {
+	type A struct{}
+
+	t.Run("MissingVariables", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Host string `env:"FX_TEST_MISSING_HOST"`
+			Port int    `env:"FX_TEST_MISSING_PORT"`
+		}
+
+		app := NewForTest(t,
+			fx.Provide(func(params) *A {
+				t.Fatal("constructor must not be called")
+				return nil
+			}),
+			fx.Invoke(func(*A) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			"missing required environment variables: FX_TEST_MISSING_HOST, FX_TEST_MISSING_PORT")
+	})
+
+	t.Run("NamesConstructor", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Host string `env:"FX_TEST_MISSING_HOST"`
+		}
+
+		var dot fx.DotGraph
+		app := NewForTest(t,
+			fx.Provide(
+				func(params) *A { return &A{} },
+				fx.Annotated{
+					Name:   "a",
+					Target: func(params) *A { return &A{} },
+				},
+			),
+			fx.Populate(&dot),
+			fx.Invoke(func(*A) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `go.uber.org/fx_test.TestEnv.func`)
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+		assert.NotContains(t, string(dot), "makeFuncStub")
+	})
+
+	t.Run("UnsupportedType", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(func(struct {
+				fx.In
+
+				Ports []int `env:"FX_TEST_PORTS"`
+			}) *A {
+				return &A{}
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "env field Ports has unsupported type []int")
+	})
+
+	t.Run("NameAndEnv", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(fx.Annotated{
+				Name: "a",
+				Target: func(struct {
+					fx.In
+
+					Port int `name:"port" env:"FX_TEST_PORT"`
+				}) *A {
+					return &A{}
+				},
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "env field Port cannot specify a name or group")
+	})
+
+	t.Run("EmptyDefault", func(t *testing.T) {
+		var got string
+		app := fxtest.New(t,
+			fx.Invoke(func(p struct {
+				fx.In
+
+				Prefix string `env:"FX_TEST_MISSING_PREFIX" default:""`
+			}) {
+				got = p.Prefix
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Empty(t, got)
+	})
+
+	t.Run("UnexportedField", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Invoke(func(struct {
+				fx.In
+
+				Host string `env:"FX_TEST_HOST" default:"localhost"`
+				a    *A
+			}) {
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			`unexported fields not allowed in fx.In, did you mean to export "a" (*fx_test.A)?`)
+	})
+
+	t.Run("IgnoreUnexportedField", func(t *testing.T) {
+		var host string
+		app := fxtest.New(t,
+			fx.Invoke(func(p struct {
+				fx.In `ignore-unexported:"true"`
+
+				Host string `env:"FX_TEST_HOST" default:"localhost"`
+				a    *A
+			}) {
+				host = p.Host
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, "localhost", host)
+	})
+}
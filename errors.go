@@ -0,0 +1,378 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/dig"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+	"go.uber.org/fx/internal/lifecycle"
+	"go.uber.org/multierr"
+)
+
+// ProvideError is returned by App.Err when a constructor could not be
+// provided to the application. Use errors.As to inspect it.
+//
+//	var provideErr *fx.ProvideError
+//	if errors.As(app.Err(), &provideErr) {
+//	  log.Printf("could not provide %v", provideErr.Constructor)
+//	}
+type ProvideError struct {
+	// Constructor is the constructor passed to fx.Provide. This may be an
+	// fx.Annotated.
+	Constructor interface{}
+
+	// Stack is where the constructor was provided from.
+	Stack fxreflect.Stack
+
+	// Err is the underlying error, usually reported by the dependency
+	// injection container.
+	Err error
+
+	// Name of the option the constructor was passed to, if it's not
+	// fx.Provide.
+	option string
+}
+
+func (e *ProvideError) Error() string {
+	name := fmt.Sprint(e.Constructor)
+	if _, ok := e.Constructor.(Annotated); !ok {
+		name = fxreflect.FuncName(e.Constructor)
+	}
+	option := e.option
+	if len(option) == 0 {
+		option = "fx.Provide"
+	}
+	return fmt.Sprintf("%v(%v) from:\n%+vFailed: %v", option, name, e.Stack, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProvideError) Unwrap() error {
+	return e.Err
+}
+
+// InvokeError is returned by App.Err when a function passed to fx.Invoke
+// or the condition of an fx.When could not be run or returned an error, and
+// by App.Start when a function passed to fx.InvokeAfterStart returned an
+// error. Use errors.As to inspect it.
+type InvokeError struct {
+	// Function is the function passed to fx.Invoke, fx.InvokeAfterStart,
+	// or fx.When.
+	Function interface{}
+
+	// Stack is where the function was invoked from.
+	Stack fxreflect.Stack
+
+	// Err is the underlying error. This is either the error returned by
+	// the function or the error reported by the dependency injection
+	// container.
+	Err error
+
+	// Explains which dependency is missing, if any. See explainMissing.
+	explanation string
+
+	// Name of the option the function was passed to, if it's not
+	// fx.Invoke. The message names the function and where it was passed
+	// if it's set.
+	option string
+}
+
+// Error returns the message of the underlying error. If the function
+// could not be run because a dependency is missing, the message also
+// explains how the function depends on it and lists similar values that
+// are provided.
+func (e *InvokeError) Error() string {
+	msg := e.Err.Error() + e.explanation
+	if len(e.option) == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%v(%v) from:\n%+vFailed: %v",
+		e.option, fxreflect.FuncName(e.Function), e.Stack, msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *InvokeError) Unwrap() error {
+	return e.Err
+}
+
+// LifecycleError is returned by App.Start and App.Stop when an OnStart or
+// OnStop hook panics in an application built with RecoverFromPanics. Its
+// Err is the PanicError reported in place of the panic. If more than one
+// hook fails, the returned error combines the errors of the failed hooks;
+// use errors.As to retrieve the first LifecycleError.
+//
+// Errors returned by hooks are returned by App.Start and App.Stop as-is, so
+// that they may be compared with the errors a hook is known to return.
+type LifecycleError struct {
+	// Hook is the kind of hook that failed: OnStart or OnStop.
+	Hook string
+
+	// Function is the hook function that failed.
+	Function func(context.Context) error
+
+	// Caller is the name of the function that appended the hook, and File
+	// and Line are the location in that function where it was appended.
+	Caller string
+	File   string
+	Line   int
+
+	// Err is the PanicError reported in place of the hook's panic.
+	Err error
+}
+
+// Error returns the message of the PanicError.
+func (e *LifecycleError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the PanicError.
+func (e *LifecycleError) Unwrap() error {
+	return e.Err
+}
+
+// newLifecycleError converts panics recovered from hooks, which the
+// lifecycle reports as HookErrors, into LifecycleErrors wrapping
+// PanicErrors, leaving other errors as-is.
+func newLifecycleError(err error) error {
+	errs := multierr.Errors(err)
+	for i, err := range errs {
+		if e, ok := err.(*lifecycle.HookError); ok {
+			lerr := &LifecycleError{
+				Hook:     e.Method,
+				Function: e.Func,
+				Caller:   e.CallerFrame.Function,
+				File:     e.CallerFrame.File,
+				Line:     e.CallerFrame.Line,
+				Err:      e.Err,
+			}
+			if p, ok := e.Err.(*lifecycle.PanicError); ok {
+				lerr.Err = &PanicError{
+					Function: e.Func,
+					Caller:   lerr.Caller,
+					File:     lerr.File,
+					Line:     lerr.Line,
+					Value:    p.Value,
+					Stack:    string(p.Stack),
+				}
+			}
+			errs[i] = lerr
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// explainMissing explains which value transitively requested by n is not
+// provided, if any, in the form,
+//
+//  dependency path:
+//  	go.uber.org/foo.Run() (foo/run.go:42)
+//  	-> *foo.Server provided by go.uber.org/foo.NewServer() (foo/server.go:12)
+//  	-> *foo.Config (missing)
+//  similar values are provided:
+//  	foo.Config (a value, not a pointer) by go.uber.org/foo.NewConfig() (foo/config.go:8)
+func explainMissing(g *graph.Graph, n *graph.Node) string {
+	path, ok := g.MissingPath(n)
+	if !ok {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\ndependency path:")
+	fmt.Fprintf(&sb, "\n\t%v%v", fxreflect.FuncName(n.Func), location(n.Stack))
+	for _, step := range path {
+		if step.Provider == nil {
+			fmt.Fprintf(&sb, "\n\t-> %v (missing)", step.Key)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n\t-> %v provided by %v%v",
+			step.Key, fxreflect.FuncName(step.Provider.Func), location(step.Provider.Stack))
+	}
+
+	missing := path[len(path)-1].Key
+	if candidates := g.NearMisses(missing); len(candidates) > 0 {
+		sb.WriteString("\nsimilar values are provided:")
+		for _, c := range candidates {
+			fmt.Fprintf(&sb, "\n\t%v (%v) by %v%v",
+				c.Key, c.Reason, fxreflect.FuncName(c.Provider.Func), location(c.Provider.Stack))
+		}
+	}
+	return sb.String()
+}
+
+// location formats the location of the first caller in the stack outside
+// Fx as " (file:line)", or an empty string if it's unknown.
+func location(stack fxreflect.Stack) string {
+	f, ok := stack.Caller()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (%v:%v)", f.File, f.Line)
+}
+
+// containerError is an error reported by the container, described in terms
+// of the functions passed to Fx. The container describes the functions
+// that Fx builds on their behalf as reflect.makeFuncStub.
+type containerError struct {
+	msg string
+
+	// Values on the path to the failure, with the value that caused it
+	// last. Used to draw the failure in the DOT graph.
+	failed []fxreflect.Key
+
+	// Root cause of the error, as reported by the container.
+	cause error
+}
+
+func (e *containerError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the root cause of the error.
+func (e *containerError) Unwrap() error {
+	return e.cause
+}
+
+// containerError describes err, reported by the container for a call to
+// the function n, in the same form as the container does, using the graph
+// to name the functions involved. n may be a function that Fx calls on its
+// own behalf, with a nil Func, in which case the description starts with
+// the value that it failed to get. Errors returned by n itself, and errors
+// that can't be explained by the graph, are returned as-is.
+func (app *App) containerError(n *graph.Node, err error) error {
+	root := dig.RootCause(err)
+	if err == nil || root == err {
+		return err
+	}
+
+	if dig.IsCycleDetected(err) {
+		cycle, ok := app.graph.Cycle()
+		if !ok {
+			return err
+		}
+
+		var sb strings.Builder
+		sb.WriteString("cycle detected in dependency graph: ")
+		for i, step := range cycle {
+			if i > 0 {
+				sb.WriteString("\n\tdepends on ")
+			}
+			fmt.Fprintf(&sb, "%v provided by %v", step.Key, describeFunc(step.Provider))
+		}
+		return &containerError{msg: sb.String(), cause: root}
+	}
+
+	var (
+		path []graph.Step
+		// Whether the last step of the path is the value that failed,
+		// rather than the constructor that it's missing for.
+		failed    bool
+		inherited bool
+	)
+	runErr, ok := root.(*runError)
+	if ok {
+		path, _ = app.graph.PathTo(n, runErr.node)
+		failed, inherited = true, app.inherited[runErr.node]
+	} else if path, ok = app.graph.MissingPath(n); !ok {
+		return err
+	}
+
+	var (
+		sb   strings.Builder
+		fn   = n
+		keys = make([]fxreflect.Key, len(path))
+	)
+	for i, step := range path {
+		keys[i] = step.Key
+		if step.Provider == nil {
+			// The value that's missing.
+			break
+		}
+
+		if fn.Func != nil {
+			fmt.Fprintf(&sb, "could not build arguments for function %v: ", describeFunc(fn))
+		}
+		if inherited && i == len(path)-1 {
+			// The parent application describes how it failed to
+			// build the value itself.
+			break
+		}
+		if len(step.Key.Group) > 0 {
+			fmt.Fprintf(&sb, "could not build value group %v: ", step.Key)
+		} else {
+			fmt.Fprintf(&sb, "failed to build %v: ", step.Key)
+		}
+		fn = step.Provider
+	}
+
+	switch {
+	case inherited:
+		sb.WriteString(runErr.err.Error())
+	case failed:
+		fmt.Fprintf(&sb, "received non-nil error from function %v: %v", describeFunc(runErr.node), runErr.err)
+	case fn.Func != nil:
+		fmt.Fprintf(&sb, "missing dependencies for function %v: %v", describeFunc(fn), root)
+	default:
+		sb.WriteString(root.Error())
+	}
+	return &containerError{msg: sb.String(), failed: keys, cause: root}
+}
+
+// provideError describes err, reported by the container when the
+// constructor n was provided to it, using the graph to name the
+// constructors involved. Errors for values that aren't functions are
+// returned as-is.
+func (app *App) provideError(n *graph.Node, err error) error {
+	if t := reflect.TypeOf(n.Func); t == nil || t.Kind() != reflect.Func {
+		return err
+	}
+
+	for _, k := range n.Results {
+		if len(k.Group) > 0 || len(app.graph.Providers(k)) == 0 {
+			continue
+		}
+
+		providers := make([]string, len(app.graph.Providers(k)))
+		for i, p := range app.graph.Providers(k) {
+			providers[i] = describeFunc(p)
+		}
+		return &containerError{
+			msg: fmt.Sprintf("cannot provide function %v: cannot provide %v: already provided by %v",
+				describeFunc(n), k, strings.Join(providers, "; ")),
+			cause: dig.RootCause(err),
+		}
+	}
+
+	return &containerError{
+		msg:   fmt.Sprintf("cannot provide function %v: %v", describeFunc(n), dig.RootCause(err)),
+		cause: dig.RootCause(err),
+	}
+}
+
+// describeFunc describes the function n as the name of the function
+// followed by where it was passed to Fx from.
+func describeFunc(n *graph.Node) string {
+	return fxreflect.FuncName(n.Func) + location(n.Stack)
+}
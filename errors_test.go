@@ -0,0 +1,145 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestProvideError(t *testing.T) {
+	err := NewForTest(t, fx.Provide(42)).Err()
+	require.Error(t, err)
+
+	var provideErr *fx.ProvideError
+	require.True(t, errors.As(err, &provideErr), "expected a ProvideError, got %T", err)
+	assert.Equal(t, 42, provideErr.Constructor)
+	assert.Contains(t, provideErr.Stack.CallerName(), "TestProvideError")
+	assert.Contains(t, provideErr.Err.Error(), "must provide constructor function")
+	assert.Equal(t, provideErr.Err, errors.Unwrap(provideErr))
+	assert.Contains(t, err.Error(), "fx.Provide(42) from:")
+}
+
+func TestInvokeError(t *testing.T) {
+	t.Run("ReturnedError", func(t *testing.T) {
+		sadness := errors.New("great sadness")
+		run := func() error { return sadness }
+
+		err := NewForTest(t, fx.Invoke(run)).Err()
+		require.Error(t, err)
+		assert.EqualError(t, err, "great sadness")
+		assert.True(t, errors.Is(err, sadness))
+
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError, got %T", err)
+		assert.NotNil(t, invokeErr.Function)
+		assert.Contains(t, invokeErr.Stack.CallerName(), "TestInvokeError")
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		type A struct{}
+
+		app := NewForTest(t, fx.Invoke(func(*A) {}))
+		err := app.Err()
+		require.Error(t, err)
+
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError, got %T", err)
+		assert.Contains(t, invokeErr.Err.Error(), "missing type: *fx_test.A")
+	})
+
+	t.Run("ExplainsMissingDependency", func(t *testing.T) {
+		type Config struct{}
+		type Server struct{}
+
+		newServer := func(*Config) *Server { return &Server{} }
+		newConfig := func() Config { return Config{} }
+		newNamedConfig := func() *Config { return &Config{} }
+		run := func(*Server) {}
+
+		app := NewForTest(t,
+			fx.Provide(newServer, newConfig),
+			fx.Provide(fx.Annotated{Name: "foo", Target: newNamedConfig}),
+			fx.Invoke(run),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		msg := err.Error()
+		assert.Contains(t, msg, "missing type: *fx_test.Config")
+		assert.Regexp(t, `dependency path:\n`+
+			`\tgo.uber.org/fx_test.TestInvokeError.func\d+.4\(\) \(.+errors_test.go:\d+\)\n`+
+			`\t-> \*fx_test.Server provided by go.uber.org/fx_test.TestInvokeError.func\d+.1\(\) \(.+errors_test.go:\d+\)\n`+
+			`\t-> \*fx_test.Config \(missing\)\n`, msg)
+		assert.Regexp(t, `similar values are provided:\n`+
+			`\tfx_test.Config \(a value, not a pointer\) by go.uber.org/fx_test.TestInvokeError.func\d+.2\(\) \(.+errors_test.go:\d+\)\n`+
+			`\t\*fx_test.Config\[name = "foo"\] \(named "foo"\) by go.uber.org/fx_test.TestInvokeError.func\d+.3\(\) \(.+errors_test.go:\d+\)`, msg)
+	})
+}
+
+func TestLifecycleError(t *testing.T) {
+	t.Run("HookErrorsAreReturnedAsIs", func(t *testing.T) {
+		sadness := errors.New("great sadness")
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnStop: func(context.Context) error { return sadness },
+				})
+			}),
+		)
+		app.RequireStart()
+
+		assert.Equal(t, sadness, app.Stop(context.Background()))
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnStop: func(context.Context) error { panic("great sadness") },
+				})
+			}),
+		)
+		app.RequireStart()
+
+		err := app.Stop(context.Background())
+		require.Error(t, err)
+
+		var lcErr *fx.LifecycleError
+		require.True(t, errors.As(err, &lcErr), "expected a LifecycleError, got %T", err)
+		assert.Equal(t, "OnStop", lcErr.Hook)
+		assert.NotNil(t, lcErr.Function)
+		assert.Contains(t, lcErr.Caller, "TestLifecycleError")
+		assert.Contains(t, lcErr.File, "errors_test.go")
+		assert.NotZero(t, lcErr.Line)
+
+		var panicErr *fx.PanicError
+		require.True(t, errors.As(err, &panicErr), "expected a PanicError, got %T", err)
+		assert.Equal(t, "great sadness", panicErr.Value)
+	})
+}
@@ -57,15 +57,44 @@ func (l *ConsoleLogger) LogEvent(event Event) {
 		l.logf("Error after options were applied: %v", e.Err)
 	case *Supply:
 		l.logf("SUPPLY\t%v", e.TypeName)
+	case *Default:
+		l.logf("DEFAULT\t%v", e.TypeName)
 	case *Provide:
 		for _, rtype := range e.OutputTypeNames {
 			l.logf("PROVIDE\t%v <= %v", rtype, fxreflect.FuncName(e.Constructor))
 		}
+	case *Duplicate:
+		l.logf("DUPLICATE\t%v provided again from:\n%vPreviously provided from:\n%vIgnoring duplicate.",
+			fxreflect.FuncName(e.Constructor), e.Stacktrace, e.PreviousStacktrace)
+	case *Unused:
+		for _, rtype := range e.OutputTypeNames {
+			l.logf("UNUSED\t%v <= %v", rtype, fxreflect.FuncName(e.Constructor))
+		}
+	case *Run:
+		if e.Err != nil {
+			l.logf("RUN\t\t%s failed in %s: %v", fxreflect.FuncName(e.Constructor), e.Runtime, e.Err)
+		} else {
+			l.logf("RUN\t\t%s ran successfully in %s", fxreflect.FuncName(e.Constructor), e.Runtime)
+		}
 	case *Invoke:
 		l.logf("INVOKE\t\t%s", fxreflect.FuncName(e.Function))
+	case *Condition:
+		switch {
+		case e.Err != nil:
+			l.logf("WHEN\t\t%s failed: %v", conditionName(e), e.Err)
+		case e.Result:
+			l.logf("WHEN\t\t%s held, applying %v", conditionName(e), e.Options)
+		default:
+			l.logf("WHEN\t\t%s did not hold, skipping %v", conditionName(e), e.Options)
+		}
 	case *InvokeError:
 		l.logf("fx.Invoke(%v) called from:\n%+vFailed: %v",
 			fxreflect.FuncName(e.Function), e.Stacktrace, e.Err)
+	case *InvokeAfterStart:
+		l.logf("AFTER START\t%s", fxreflect.FuncName(e.Function))
+	case *InvokeAfterStartError:
+		l.logf("fx.InvokeAfterStart(%v) called from:\n%+vFailed: %v",
+			fxreflect.FuncName(e.Function), e.Stacktrace, e.Err)
 	case *StartError:
 		l.logf("ERROR\t\tFailed to start: %v", e.Err)
 	case *StopSignal:
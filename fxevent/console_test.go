@@ -79,6 +79,11 @@ func TestConsoleLogger(t *testing.T) {
 			give: &Supply{TypeName: "*bytes.Buffer"},
 			want: "[Fx] SUPPLY	*bytes.Buffer\n",
 		},
+		{
+			name: "Default",
+			give: &Default{TypeName: "*bytes.Buffer"},
+			want: "[Fx] DEFAULT	*bytes.Buffer\n",
+		},
 		{
 			name: "Provide",
 			give: &Provide{bytes.NewBuffer, []string{"*bytes.Buffer"}},
@@ -89,6 +94,77 @@ func TestConsoleLogger(t *testing.T) {
 			give: &Invoke{bytes.NewBuffer},
 			want: "[Fx] INVOKE		bytes.NewBuffer()\n",
 		},
+		{
+			name: "Unused",
+			give: &Unused{
+				Constructor:     bytes.NewBuffer,
+				OutputTypeNames: []string{"*bytes.Buffer"},
+			},
+			want: "[Fx] UNUSED	*bytes.Buffer <= bytes.NewBuffer()\n",
+		},
+		{
+			name: "Run",
+			give: &Run{
+				Constructor: bytes.NewBuffer,
+				Runtime:     3 * time.Millisecond,
+			},
+			want: "[Fx] RUN\t\tbytes.NewBuffer() ran successfully in 3ms\n",
+		},
+		{
+			name: "RunError",
+			give: &Run{
+				Constructor: bytes.NewBuffer,
+				Runtime:     3 * time.Millisecond,
+				Err:         errors.New("some error"),
+			},
+			want: "[Fx] RUN\t\tbytes.NewBuffer() failed in 3ms: some error\n",
+		},
+		{
+			name: "Duplicate",
+			give: &Duplicate{
+				Constructor:        bytes.NewBuffer,
+				Stacktrace:         "foo()\n\tfoo.go:1\n",
+				PreviousStacktrace: "bar()\n\tbar.go:2\n",
+			},
+			want: "[Fx] DUPLICATE	bytes.NewBuffer() provided again from:\n" +
+				"foo()\n\tfoo.go:1\n" +
+				"Previously provided from:\n" +
+				"bar()\n\tbar.go:2\n" +
+				"Ignoring duplicate.\n",
+		},
+		{
+			name: "Condition",
+			give: &Condition{
+				Function: bytes.NewBuffer,
+				Options:  "fx.Provide(bytes.NewReader())",
+				Result:   true,
+			},
+			want: "[Fx] WHEN		bytes.NewBuffer() held, applying fx.Provide(bytes.NewReader())\n",
+		},
+		{
+			name: "ConditionSkipped",
+			give: &Condition{
+				Function: bytes.NewBuffer,
+				Options:  "fx.Provide(bytes.NewReader())",
+			},
+			want: "[Fx] WHEN		bytes.NewBuffer() did not hold, skipping fx.Provide(bytes.NewReader())\n",
+		},
+		{
+			name: "ConditionError",
+			give: &Condition{
+				Function: bytes.NewBuffer,
+				Err:      errors.New("some error"),
+			},
+			want: "[Fx] WHEN		bytes.NewBuffer() failed: some error\n",
+		},
+		{
+			name: "ConditionIf",
+			give: &Condition{
+				Options: "fx.Provide(bytes.NewReader())",
+				Result:  true,
+			},
+			want: "[Fx] WHEN		fx.If held, applying fx.Provide(bytes.NewReader())\n",
+		},
 		{
 			name: "InvokeError",
 			give: &InvokeError{
@@ -103,6 +179,25 @@ func TestConsoleLogger(t *testing.T) {
 				"Failed: some error",
 			),
 		},
+		{
+			name: "InvokeAfterStart",
+			give: &InvokeAfterStart{bytes.NewBuffer},
+			want: "[Fx] AFTER START	bytes.NewBuffer()\n",
+		},
+		{
+			name: "InvokeAfterStartError",
+			give: &InvokeAfterStartError{
+				Function:   bytes.NewBuffer,
+				Err:        errors.New("some error"),
+				Stacktrace: "foo()\n\tbar/baz.go:42\n",
+			},
+			want: joinLines(
+				"[Fx] fx.InvokeAfterStart(bytes.NewBuffer()) called from:",
+				"foo()",
+				"	bar/baz.go:42",
+				"Failed: some error",
+			),
+		},
 		{
 			name: "StartError",
 			give: &StartError{Err: errors.New("some error")},
@@ -23,6 +23,8 @@ package fxevent
 import (
 	"os"
 	"time"
+
+	"go.uber.org/fx/internal/fxreflect"
 )
 
 // Event defines an event emitted by fx.
@@ -35,9 +37,16 @@ func (*LifecycleHookExecuting) event() {}
 func (*LifecycleHookExecuted) event()  {}
 func (*ProvideError) event()           {}
 func (*Supply) event()                 {}
+func (*Default) event()                {}
 func (*Provide) event()                {}
+func (*Duplicate) event()              {}
+func (*Unused) event()                 {}
+func (*Run) event()                    {}
 func (*Invoke) event()                 {}
+func (*Condition) event()              {}
 func (*InvokeError) event()            {}
+func (*InvokeAfterStart) event()       {}
+func (*InvokeAfterStartError) event()  {}
 func (*StartError) event()             {}
 func (*StopSignal) event()             {}
 func (*StopError) event()              {}
@@ -77,6 +86,13 @@ type Supply struct {
 	TypeName string
 }
 
+// Default is emitted whenever a value registered with fx.Default is added to
+// the container because no other constructor provides its type. It's emitted
+// while the application is built, before any function consumes the value.
+type Default struct {
+	TypeName string
+}
+
 // Provide is emitted when we add a constructor to the container.
 type Provide struct {
 	Constructor interface{}
@@ -86,11 +102,79 @@ type Provide struct {
 	OutputTypeNames []string
 }
 
+// Duplicate is emitted whenever a constructor is provided again with the
+// same annotations, like by an option included more than once, and the
+// duplicate constructor is ignored.
+type Duplicate struct {
+	// Constructor is the duplicate constructor.
+	Constructor interface{}
+
+	// Stacktrace is where the option was included from again: where it was
+	// created, followed by where the fx.Options enclosing it were created.
+	Stacktrace string
+
+	// PreviousStacktrace is where the option was first included from.
+	PreviousStacktrace string
+}
+
+// Unused is emitted for each constructor whose results are never requested
+// by an invoked function, if the application was built with fx.Strict or
+// fx.ReportUnused.
+type Unused struct {
+	// Constructor is the unused constructor.
+	Constructor interface{}
+
+	// OutputTypeNames holds the types the constructor provides.
+	OutputTypeNames []string
+
+	// Stacktrace is where the constructor was provided from.
+	Stacktrace string
+}
+
+// Run is emitted after a constructor provided to Fx has run because one of
+// its results was requested.
+type Run struct {
+	// Constructor is the constructor that ran.
+	Constructor interface{}
+
+	// Runtime is how long the constructor took to run.
+	Runtime time.Duration
+
+	// Err is non-nil if the constructor failed.
+	Err error
+}
+
 // Invoke is emitted whenever a function is invoked.
 type Invoke struct {
 	Function interface{}
 }
 
+// Condition is emitted whenever the condition of an fx.When option is
+// evaluated, and for every fx.If option.
+type Condition struct {
+	// Function is the condition function. This is nil for fx.If.
+	Function interface{}
+
+	// Options is a string representation of the options guarded by the
+	// condition.
+	Options string
+
+	// Result reports whether the condition held and the options were
+	// applied.
+	Result bool
+
+	// Err is non-nil if the condition could not be evaluated.
+	Err error
+}
+
+// conditionName returns the name of the condition reported by e.
+func conditionName(e *Condition) string {
+	if e.Function == nil {
+		return "fx.If"
+	}
+	return fxreflect.FuncName(e.Function)
+}
+
 // InvokeError is emitted when fx.Invoke has failed.
 type InvokeError struct {
 	Function   interface{}
@@ -98,6 +182,20 @@ type InvokeError struct {
 	Stacktrace string
 }
 
+// InvokeAfterStart is emitted whenever a function passed to
+// fx.InvokeAfterStart is run after the application started.
+type InvokeAfterStart struct {
+	Function interface{}
+}
+
+// InvokeAfterStartError is emitted when a function passed to
+// fx.InvokeAfterStart has failed. The application is rolled back.
+type InvokeAfterStartError struct {
+	Function   interface{}
+	Err        error
+	Stacktrace string
+}
+
 // StartError is emitted right before exiting after failing to start.
 type StartError struct{ Err error }
 
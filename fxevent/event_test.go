@@ -33,9 +33,16 @@ func TestForCoverage(t *testing.T) {
 		&LifecycleHookExecuted{},
 		&ProvideError{},
 		&Supply{},
+		&Default{},
 		&Provide{},
 		&Invoke{},
+		&Condition{},
+		&Duplicate{},
+		&Unused{},
+		&Run{},
 		&InvokeError{},
+		&InvokeAfterStart{},
+		&InvokeAfterStartError{},
 		&StartError{},
 		&StopSignal{},
 		&StopError{},
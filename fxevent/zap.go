@@ -64,6 +64,8 @@ func (l *ZapLogger) LogEvent(event Event) {
 			zap.Error(e.Err))
 	case *Supply:
 		l.Logger.Info("supplying", zap.String("type", e.TypeName))
+	case *Default:
+		l.Logger.Info("using default", zap.String("type", e.TypeName))
 	case *Provide:
 		for _, rtype := range e.OutputTypeNames {
 			l.Logger.Info("providing",
@@ -74,11 +76,56 @@ func (l *ZapLogger) LogEvent(event Event) {
 	case *Invoke:
 		l.Logger.Info("invoke",
 			zap.String("function", fxreflect.FuncName(e.Function)))
+	case *Duplicate:
+		l.Logger.Info("ignoring duplicate constructor",
+			zap.String("constructor", fxreflect.FuncName(e.Constructor)),
+			zap.String("stack", e.Stacktrace),
+			zap.String("previous_stack", e.PreviousStacktrace))
+	case *Unused:
+		for _, rtype := range e.OutputTypeNames {
+			l.Logger.Warn("unused constructor",
+				zap.String("constructor", fxreflect.FuncName(e.Constructor)),
+				zap.String("type", rtype),
+				zap.String("stack", e.Stacktrace),
+			)
+		}
+	case *Run:
+		if e.Err != nil {
+			l.Logger.Error("constructor failed",
+				zap.String("constructor", fxreflect.FuncName(e.Constructor)),
+				zap.String("runtime", e.Runtime.String()),
+				zap.Error(e.Err),
+			)
+		} else {
+			l.Logger.Info("constructor ran",
+				zap.String("constructor", fxreflect.FuncName(e.Constructor)),
+				zap.String("runtime", e.Runtime.String()),
+			)
+		}
+	case *Condition:
+		if e.Err != nil {
+			l.Logger.Error("condition failed",
+				zap.Error(e.Err),
+				zap.String("function", conditionName(e)))
+		} else {
+			l.Logger.Info("condition evaluated",
+				zap.String("function", conditionName(e)),
+				zap.Bool("result", e.Result),
+				zap.String("options", e.Options))
+		}
 	case *InvokeError:
 		l.Logger.Error("fx.Invoke failed",
 			zap.Error(e.Err),
 			zap.String("stack", e.Stacktrace),
 			zap.String("function", fxreflect.FuncName(e.Function)))
+	case *InvokeAfterStart:
+		l.Logger.Info("invoke after start",
+			zap.String("function", fxreflect.FuncName(e.Function)))
+	case *InvokeAfterStartError:
+		l.Logger.Error("fx.InvokeAfterStart failed",
+			zap.Error(e.Err),
+			zap.String("stack", e.Stacktrace),
+			zap.String("function", fxreflect.FuncName(e.Function)))
 	case *StartError:
 		l.Logger.Error("failed to start", zap.Error(e.Err))
 	case *StopSignal:
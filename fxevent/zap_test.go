@@ -107,6 +107,14 @@ func TestZapLogger(t *testing.T) {
 				"type": "*bytes.Buffer",
 			},
 		},
+		{
+			name:        "Default",
+			give:        &Default{TypeName: "*bytes.Buffer"},
+			wantMessage: "using default",
+			wantFields: map[string]interface{}{
+				"type": "*bytes.Buffer",
+			},
+		},
 		{
 			name:        "Provide",
 			give:        &Provide{bytes.NewBuffer, []string{"*bytes.Buffer"}},
@@ -124,6 +132,93 @@ func TestZapLogger(t *testing.T) {
 				"function": "bytes.NewBuffer()",
 			},
 		},
+		{
+			name: "Unused",
+			give: &Unused{
+				Constructor:     bytes.NewBuffer,
+				OutputTypeNames: []string{"*bytes.Buffer"},
+				Stacktrace:      "foo()",
+			},
+			wantMessage: "unused constructor",
+			wantFields: map[string]interface{}{
+				"constructor": "bytes.NewBuffer()",
+				"type":        "*bytes.Buffer",
+				"stack":       "foo()",
+			},
+		},
+		{
+			name: "Run",
+			give: &Run{
+				Constructor: bytes.NewBuffer,
+				Runtime:     3 * time.Millisecond,
+			},
+			wantMessage: "constructor ran",
+			wantFields: map[string]interface{}{
+				"constructor": "bytes.NewBuffer()",
+				"runtime":     "3ms",
+			},
+		},
+		{
+			name: "RunError",
+			give: &Run{
+				Constructor: bytes.NewBuffer,
+				Runtime:     3 * time.Millisecond,
+				Err:         someError,
+			},
+			wantMessage: "constructor failed",
+			wantFields: map[string]interface{}{
+				"constructor": "bytes.NewBuffer()",
+				"runtime":     "3ms",
+				"error":       "some error",
+			},
+		},
+		{
+			name: "Duplicate",
+			give: &Duplicate{
+				Constructor:        bytes.NewBuffer,
+				Stacktrace:         "foo()",
+				PreviousStacktrace: "bar()",
+			},
+			wantMessage: "ignoring duplicate constructor",
+			wantFields: map[string]interface{}{
+				"constructor":    "bytes.NewBuffer()",
+				"stack":          "foo()",
+				"previous_stack": "bar()",
+			},
+		},
+		{
+			name: "Condition",
+			give: &Condition{
+				Function: bytes.NewBuffer,
+				Options:  "fx.Provide(bytes.NewReader())",
+				Result:   true,
+			},
+			wantMessage: "condition evaluated",
+			wantFields: map[string]interface{}{
+				"function": "bytes.NewBuffer()",
+				"result":   true,
+				"options":  "fx.Provide(bytes.NewReader())",
+			},
+		},
+		{
+			name:        "ConditionError",
+			give:        &Condition{Function: bytes.NewBuffer, Err: someError},
+			wantMessage: "condition failed",
+			wantFields: map[string]interface{}{
+				"function": "bytes.NewBuffer()",
+				"error":    "some error",
+			},
+		},
+		{
+			name:        "ConditionIf",
+			give:        &Condition{Options: "fx.Provide(bytes.NewReader())"},
+			wantMessage: "condition evaluated",
+			wantFields: map[string]interface{}{
+				"function": "fx.If",
+				"result":   false,
+				"options":  "fx.Provide(bytes.NewReader())",
+			},
+		},
 		{
 			name:        "InvokeError",
 			give:        &InvokeError{Function: bytes.NewBuffer, Err: someError},
@@ -134,6 +229,24 @@ func TestZapLogger(t *testing.T) {
 				"function": "bytes.NewBuffer()",
 			},
 		},
+		{
+			name:        "InvokeAfterStart",
+			give:        &InvokeAfterStart{bytes.NewBuffer},
+			wantMessage: "invoke after start",
+			wantFields: map[string]interface{}{
+				"function": "bytes.NewBuffer()",
+			},
+		},
+		{
+			name:        "InvokeAfterStartError",
+			give:        &InvokeAfterStartError{Function: bytes.NewBuffer, Err: someError},
+			wantMessage: "fx.InvokeAfterStart failed",
+			wantFields: map[string]interface{}{
+				"error":    "some error",
+				"stack":    "",
+				"function": "bytes.NewBuffer()",
+			},
+		},
 		{
 			name:        "StartError",
 			give:        &StartError{Err: someError},
@@ -0,0 +1,235 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package fxflag binds command line flags to an Fx application.
+//
+// Flags are declared with options like Int and String, and parsed by the
+// Parse option. The value of each flag is then available in the container
+// under the name returned by Name.
+//
+//  app := fx.New(
+//    fxflag.Parse(flag.CommandLine, os.Args[1:]),
+//    fxflag.Int("port", 8080, "port to listen on"),
+//    fx.Invoke(func(p struct {
+//      fx.In
+//
+//      Port int `name:"flag.port"`
+//    }) {
+//      // ...
+//    }),
+//  )
+//
+// The usage of each flag is extended with the constructors and invoked
+// functions that consume it, unless the flags are parsed to evaluate a
+// condition passed to fx.When, before fx.Graph is available.
+package fxflag
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// FlagSet is a set of flags that can be parsed by fxflag. It is satisfied by
+// *flag.FlagSet. Other flag libraries may be used by adapting them to this
+// interface.
+type FlagSet interface {
+	Var(value flag.Value, name string, usage string)
+	Parse(arguments []string) error
+}
+
+var _ FlagSet = (*flag.FlagSet)(nil)
+
+// Prefix is prepended to the names of flags to build the names of the
+// values they are provided under.
+const Prefix = "flag."
+
+// Name returns the name under which the value of the given flag is provided
+// to the container. Consume it with a `name:".."` tag. For example,
+//
+//  type Params struct {
+//    fx.In
+//
+//    Port int `name:"flag.port"`
+//  }
+func Name(flag string) string {
+	return Prefix + flag
+}
+
+const _flagsGroup = "fxflag.flags"
+
+// parsed is produced once all flags have been declared and parsed.
+type parsed struct{}
+
+// definition is a flag declared with one of the options in this package.
+type definition struct {
+	Flag *flag.Flag
+	Type reflect.Type // type of the provided value
+}
+
+// Parse declares all flags registered with this package on the given
+// FlagSet and parses the given arguments before any functions that depend
+// on the flags are run.
+//
+// Parse must be used exactly once for an application that uses flags
+// declared with this package.
+func Parse(fs FlagSet, args []string) fx.Option {
+	return fx.Options(
+		fx.Provide(func(p parseParams) (*parsed, error) {
+			return parse(fs, args, p)
+		}),
+		fx.Invoke(func(*parsed) {}),
+	)
+}
+
+type parseParams struct {
+	fx.In
+
+	Flags []*definition `group:"fxflag.flags"`
+	Graph fx.Graph      `optional:"true"`
+}
+
+func parse(fs FlagSet, args []string, p parseParams) (*parsed, error) {
+	seen := make(map[string]struct{}, len(p.Flags))
+	for _, def := range p.Flags {
+		f := def.Flag
+		if _, ok := seen[f.Name]; ok {
+			return nil, fmt.Errorf("flag %q declared more than once", f.Name)
+		}
+		seen[f.Name] = struct{}{}
+
+		usage := f.Usage
+		if names := consumers(p.Graph, def.Type, Name(f.Name)); len(names) > 0 {
+			usage = fmt.Sprintf("%v (used by %v)", usage, strings.Join(names, ", "))
+		}
+		fs.Var(f.Value, f.Name, usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return &parsed{}, nil
+}
+
+// consumers returns the names of the functions in the graph that consume
+// the value of the given type and name, in the order they were added.
+func consumers(g fx.Graph, t reflect.Type, name string) []string {
+	functions := make(map[string]string, len(g.Nodes)) // ID => function
+	for _, n := range g.Nodes {
+		functions[n.ID] = n.Function
+	}
+
+	var (
+		names []string
+		seen  = make(map[string]struct{})
+	)
+	for _, e := range g.Edges {
+		if e.Type != t.String() || e.Name != name {
+			continue
+		}
+		if _, ok := seen[e.To]; ok {
+			continue
+		}
+		seen[e.To] = struct{}{}
+		names = append(names, functions[e.To]+"()")
+	}
+	return names
+}
+
+// newFlag builds an option that declares the given flag and provides its
+// value with fn, a function of the form func(*parsed) T.
+func newFlag(f *flag.Flag, fn interface{}) fx.Option {
+	def := &definition{Flag: f, Type: reflect.TypeOf(fn).Out(0)}
+	return fx.Provide(
+		fx.Annotated{
+			Group:  _flagsGroup,
+			Target: func() *definition { return def },
+		},
+		fx.Annotated{
+			Name:   Name(f.Name),
+			Target: fn,
+		},
+	)
+}
+
+// Bool declares a bool flag with the given name, default value, and usage.
+func Bool(name string, value bool, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Bool(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) bool { return *p })
+}
+
+// Duration declares a time.Duration flag with the given name, default value,
+// and usage.
+func Duration(name string, value time.Duration, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Duration(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) time.Duration { return *p })
+}
+
+// Float64 declares a float64 flag with the given name, default value, and
+// usage.
+func Float64(name string, value float64, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Float64(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) float64 { return *p })
+}
+
+// Int declares an int flag with the given name, default value, and usage.
+func Int(name string, value int, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Int(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) int { return *p })
+}
+
+// Int64 declares an int64 flag with the given name, default value, and
+// usage.
+func Int64(name string, value int64, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Int64(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) int64 { return *p })
+}
+
+// String declares a string flag with the given name, default value, and
+// usage.
+func String(name string, value string, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.String(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) string { return *p })
+}
+
+// Uint declares a uint flag with the given name, default value, and usage.
+func Uint(name string, value uint, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Uint(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) uint { return *p })
+}
+
+// Uint64 declares a uint64 flag with the given name, default value, and
+// usage.
+func Uint64(name string, value uint64, usage string) fx.Option {
+	var fs flag.FlagSet
+	p := fs.Uint64(name, value, usage)
+	return newFlag(fs.Lookup(name), func(*parsed) uint64 { return *p })
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxflag_test
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxflag"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/goleak"
+)
+
+type server struct{ Port int }
+
+func newServer(p struct {
+	fx.In
+
+	Port int `name:"flag.port"`
+}) *server {
+	return &server{Port: p.Port}
+}
+
+func TestFlags(t *testing.T) {
+	t.Run("Parsed", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+
+		var got struct {
+			fx.In
+
+			Port    int           `name:"flag.port"`
+			Name    string        `name:"flag.name"`
+			Debug   bool          `name:"flag.debug"`
+			Timeout time.Duration `name:"flag.timeout"`
+			Ratio   float64       `name:"flag.ratio"`
+			Count   int64         `name:"flag.count"`
+			Workers uint          `name:"flag.workers"`
+			Limit   uint64        `name:"flag.limit"`
+		}
+		app := fxtest.New(t,
+			fxflag.Parse(fs, []string{"-port", "9090", "-debug", "-timeout", "2s"}),
+			fxflag.Int("port", 8080, "port to listen on"),
+			fxflag.String("name", "foo", "name of the service"),
+			fxflag.Bool("debug", false, "enable debugging"),
+			fxflag.Duration("timeout", time.Second, "request timeout"),
+			fxflag.Float64("ratio", 0.5, "sampling ratio"),
+			fxflag.Int64("count", 1, "count"),
+			fxflag.Uint("workers", 4, "number of workers"),
+			fxflag.Uint64("limit", 10, "limit"),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, 9090, got.Port)
+		assert.Equal(t, "foo", got.Name)
+		assert.True(t, got.Debug)
+		assert.Equal(t, 2*time.Second, got.Timeout)
+		assert.Equal(t, 0.5, got.Ratio)
+		assert.Equal(t, int64(1), got.Count)
+		assert.Equal(t, uint(4), got.Workers)
+		assert.Equal(t, uint64(10), got.Limit)
+	})
+
+	t.Run("UsageListsConsumers", func(t *testing.T) {
+		var buff bytes.Buffer
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(&buff)
+
+		app := fxtest.New(t,
+			fxflag.Parse(fs, nil),
+			fxflag.Int("port", 8080, "port to listen on"),
+			fx.Provide(newServer),
+			fx.Invoke(func(*server) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		fs.PrintDefaults()
+		assert.Contains(t, buff.String(),
+			"port to listen on (used by go.uber.org/fx/fxflag_test.newServer())")
+	})
+
+	t.Run("When", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+
+		var called bool
+		app := fxtest.New(t,
+			fxflag.Parse(fs, []string{"-debug"}),
+			fxflag.Bool("debug", false, "enable debugging"),
+			fx.When(func(p struct {
+				fx.In
+
+				Debug bool `name:"flag.debug"`
+			}) bool {
+				return p.Debug
+			}, fx.Invoke(func() { called = true })),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.True(t, called)
+	})
+
+	t.Run("ProvideEvents", func(t *testing.T) {
+		var spy fxlog.Spy
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return &spy }),
+			fxflag.Parse(flag.NewFlagSet("test", flag.ContinueOnError), nil),
+			fxflag.Int("port", 8080, "port to listen on"),
+			fx.Provide(newServer),
+			fx.Invoke(func(*server) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var constructors []string
+		for _, e := range spy.Events() {
+			if e, ok := e.(*fxevent.Provide); ok {
+				constructors = append(constructors, fxreflect.FuncName(e.Constructor))
+				assert.NotContains(t, e.OutputTypeNames, "*graph.Graph",
+					"internal types must not be provided")
+			}
+		}
+		assert.Contains(t, constructors,
+			`fx.Annotated{Name: "flag.port", Target: go.uber.org/fx/fxflag.Int.func1()}`)
+		for _, name := range constructors {
+			assert.NotContains(t, name, "makeFuncStub")
+		}
+	})
+
+	t.Run("ParseError", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(&bytes.Buffer{})
+
+		app := fx.New(
+			fx.NopLogger,
+			fxflag.Parse(fs, []string{"-unknown"}),
+			fxflag.Int("port", 8080, "port to listen on"),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "flag provided but not defined: -unknown")
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		assert.Equal(t, "flag.port", fxflag.Name("port"))
+	})
+}
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
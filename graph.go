@@ -0,0 +1,226 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+)
+
+// Graph is a structured description of the dependency graph of an
+// application. Like DotGraph, it's available in the container, but it can
+// be exported as JSON with encoding/json or as a Mermaid flowchart with the
+// Mermaid method.
+//
+//  fx.Invoke(func(g fx.Graph) error {
+//    return json.NewEncoder(os.Stdout).Encode(g)
+//  })
+//
+// Graph is a snapshot of the application taken the first time it's
+// requested. It holds all constructors and invoked functions of the
+// application, including the ones added by Populate. It's provided once all
+// options were applied, so conditions passed to When cannot depend on it.
+type Graph struct {
+	// Nodes holds the constructors provided to the application followed by
+	// the functions it invokes.
+	Nodes []GraphNode `json:"nodes"`
+
+	// Edges holds the values passed from constructors to the functions
+	// that depend on them.
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a function in the dependency graph.
+type GraphNode struct {
+	// ID uniquely identifies this node in the graph.
+	ID string `json:"id"`
+
+	// Kind is "constructor" or "invoke".
+	Kind string `json:"kind"`
+
+	// Function is the fully qualified name of the function.
+	Function string `json:"function"`
+
+	// File and Line are where the function was provided or invoked.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+
+	// Results holds the values produced by a constructor, formatted like
+	// *bytes.Buffer[name = "foo"]. Values given an order in their value
+	// group include it, as in *bytes.Buffer[group = "bar", order = 10].
+	// Always empty for invokes.
+	Results []string `json:"results,omitempty"`
+
+	// Builtin is true for constructors provided by Fx itself, like the
+	// constructor for Lifecycle, and for values inherited from the parent
+	// of an application built with NewChild.
+	Builtin bool `json:"builtin,omitempty"`
+}
+
+// GraphEdge is a value produced by one node and consumed by another.
+type GraphEdge struct {
+	// From is the ID of the constructor that produces the value.
+	From string `json:"from"`
+
+	// To is the ID of the node that consumes the value.
+	To string `json:"to"`
+
+	// Type is the type of the value, and Name and Group are its
+	// annotations, if any.
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Group string `json:"group,omitempty"`
+
+	// Order and Key are the order and the key of the value in its group,
+	// if it was given them. See Annotated.Order and Annotated.Key.
+	Order int    `json:"order,omitempty"`
+	Key   string `json:"key,omitempty"`
+
+	// Optional is true if the consumer does not require the value.
+	Optional bool `json:"optional,omitempty"`
+
+	// Lazy is true if the consumer requests a function that builds the
+	// value on demand, func() (T, error), rather than the value itself.
+	Lazy bool `json:"lazy,omitempty"`
+}
+
+const (
+	_graphConstructor = "constructor"
+	_graphInvoke      = "invoke"
+)
+
+// newGraph builds a Graph from the functions known to the application.
+func newGraph(g *graph.Graph) Graph {
+	var out Graph
+	ids := make(map[*graph.Node]string)
+
+	addNode := func(n *graph.Node, kind string) {
+		id := fmt.Sprintf("n%d", len(out.Nodes))
+		ids[n] = id
+
+		node := GraphNode{
+			ID:       id,
+			Kind:     kind,
+			Function: strings.TrimSuffix(fxreflect.FuncName(n.Func), "()"),
+			Builtin:  n.Builtin,
+		}
+		if f, ok := n.Stack.Caller(); ok {
+			node.File = f.File
+			node.Line = f.Line
+		}
+		for i, k := range n.Results {
+			order, key := resultAnnotations(n, i)
+			node.Results = append(node.Results, resultString(k, order, key))
+		}
+		out.Nodes = append(out.Nodes, node)
+	}
+	for _, n := range g.Constructors {
+		addNode(n, _graphConstructor)
+	}
+	for _, n := range g.Invokes {
+		addNode(n, _graphInvoke)
+	}
+
+	for _, nodes := range [][]*graph.Node{g.Constructors, g.Invokes} {
+		for _, n := range nodes {
+			to, ok := ids[n]
+			if !ok {
+				continue
+			}
+
+			for _, p := range n.Params {
+				k, lazy := g.Dependency(p)
+				for _, provider := range g.Providers(k) {
+					from, ok := ids[provider]
+					if !ok {
+						continue
+					}
+
+					e := GraphEdge{
+						From:     from,
+						To:       to,
+						Type:     k.Type.String(),
+						Name:     k.Name,
+						Group:    k.Group,
+						Optional: p.Optional,
+						Lazy:     lazy,
+					}
+					for i, r := range provider.Results {
+						if r == k {
+							e.Order, e.Key = resultAnnotations(provider, i)
+							break
+						}
+					}
+					out.Edges = append(out.Edges, e)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// JSON returns the graph encoded as JSON.
+func (g Graph) JSON() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// Mermaid returns the graph as a Mermaid flowchart. Constructors are drawn
+// as rectangles and invoked functions as subroutines, with an arrow from
+// each constructor to the functions that consume its values. Optional and
+// lazy dependencies are drawn with dotted arrows.
+func (g Graph) Mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for _, n := range g.Nodes {
+		if n.Kind == _graphInvoke {
+			fmt.Fprintf(&sb, "\t%v[[\"%v\"]]\n", n.ID, mermaidEscape(n.Function))
+		} else {
+			fmt.Fprintf(&sb, "\t%v[\"%v\"]\n", n.ID, mermaidEscape(n.Function))
+		}
+	}
+	for _, e := range g.Edges {
+		label := e.Type
+		switch {
+		case len(e.Name) > 0:
+			label = fmt.Sprintf("%v[name = %q]", e.Type, e.Name)
+		case len(e.Group) > 0:
+			label = annotateResult(fmt.Sprintf("%v[group = %q]", e.Type, e.Group), e.Order, e.Key)
+		}
+
+		arrow := "-->"
+		if e.Optional || e.Lazy {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "\t%v %v|\"%v\"| %v\n", e.From, arrow, mermaidEscape(label), e.To)
+	}
+	return sb.String()
+}
+
+// mermaidEscape escapes characters that can't appear in quoted Mermaid
+// labels.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;").Replace(s)
+}
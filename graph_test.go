@@ -0,0 +1,185 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type graphConfig struct{}
+
+type graphServer struct{}
+
+func newGraphConfig() *graphConfig { return &graphConfig{} }
+
+func newGraphServer(p struct {
+	fx.In
+
+	Config *graphConfig
+	Name   string `name:"name" optional:"true"`
+}) *graphServer {
+	return &graphServer{}
+}
+
+func runGraphServer(*graphServer) {}
+
+func TestGraph(t *testing.T) {
+	var g fx.Graph
+	app := fxtest.New(t,
+		fx.Provide(
+			newGraphConfig,
+			newGraphServer,
+			fx.Annotated{Name: "name", Target: func() string { return "foo" }},
+		),
+		fx.Invoke(runGraphServer),
+		fx.Populate(&g),
+	)
+	defer app.RequireStart().RequireStop()
+
+	nodes := make(map[string]fx.GraphNode)
+	ids := make(map[string]string)
+	for _, n := range g.Nodes {
+		nodes[n.Function] = n
+		ids[n.Function] = n.ID
+	}
+
+	config := nodes["go.uber.org/fx_test.newGraphConfig"]
+	assert.Equal(t, "constructor", config.Kind)
+	assert.Equal(t, []string{"*fx_test.graphConfig"}, config.Results)
+	assert.Contains(t, config.File, "graph_test.go")
+	assert.NotZero(t, config.Line)
+
+	run := nodes["go.uber.org/fx_test.runGraphServer"]
+	assert.Equal(t, "invoke", run.Kind)
+	assert.Empty(t, run.Results)
+
+	server := ids["go.uber.org/fx_test.newGraphServer"]
+	assert.Contains(t, g.Edges, fx.GraphEdge{
+		From: ids["go.uber.org/fx_test.newGraphConfig"],
+		To:   server,
+		Type: "*fx_test.graphConfig",
+	})
+	assert.Contains(t, g.Edges, fx.GraphEdge{
+		From:     ids["go.uber.org/fx_test.TestGraph.func1"],
+		To:       server,
+		Type:     "string",
+		Name:     "name",
+		Optional: true,
+	})
+	assert.Contains(t, g.Edges, fx.GraphEdge{
+		From: server,
+		To:   run.ID,
+		Type: "*fx_test.graphServer",
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		b, err := g.JSON()
+		require.NoError(t, err)
+
+		var got fx.Graph
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, g, got)
+		assert.Contains(t, string(b), `"function":"go.uber.org/fx_test.runGraphServer"`)
+	})
+
+	t.Run("Mermaid", func(t *testing.T) {
+		m := g.Mermaid()
+		assert.Contains(t, m, "flowchart LR\n")
+		assert.Contains(t, m, "\t"+config.ID+`["go.uber.org/fx_test.newGraphConfig"]`+"\n")
+		assert.Contains(t, m, "\t"+run.ID+`[["go.uber.org/fx_test.runGraphServer"]]`+"\n")
+		assert.Contains(t, m, "\t"+config.ID+` -->|"*fx_test.graphConfig"| `+server+"\n")
+		assert.Contains(t, m, "\t"+ids["go.uber.org/fx_test.TestGraph.func1"]+
+			` -.->|"string[name = #quot;name#quot;]"| `+server+"\n")
+	})
+}
+
+func TestGraphBuiltin(t *testing.T) {
+	var g fx.Graph
+	app := fxtest.New(t,
+		fx.Provide(newGraphConfig),
+		fx.Populate(&g),
+	)
+	defer app.RequireStart().RequireStop()
+
+	var builtin []string
+	for _, n := range g.Nodes {
+		if n.Builtin {
+			builtin = append(builtin, n.Function)
+		}
+		if n.Function == "go.uber.org/fx_test.newGraphConfig" {
+			assert.False(t, n.Builtin, "user constructors must not be builtin")
+		}
+	}
+	assert.Contains(t, builtin, "go.uber.org/fx.New.func1", "Lifecycle must be builtin")
+	for _, name := range builtin {
+		assert.True(t, strings.HasPrefix(name, "go.uber.org/fx."),
+			"unexpected builtin %q", name)
+	}
+}
+
+func TestGraphSnapshot(t *testing.T) {
+	// Eager constructors are called before any function is invoked, but
+	// after all invokes were recorded.
+	var g fx.Graph
+	app := fx.New(
+		fx.NopLogger,
+		fx.Provide(
+			fx.Annotated{
+				Eager:  true,
+				Target: func(g fx.Graph) *graphConfig { return &graphConfig{} },
+			},
+			newGraphServer,
+		),
+		fx.Invoke(runGraphServer),
+		fx.When(func() bool { return true }, fx.Invoke(func(*graphConfig) {})),
+		fx.Populate(&g),
+	)
+	require.NoError(t, app.Err())
+
+	// The condition, both invokes, and Populate.
+	var invokes []string
+	for _, n := range g.Nodes {
+		if n.Kind == "invoke" && strings.HasSuffix(n.File, "graph_test.go") {
+			invokes = append(invokes, n.Function)
+		}
+	}
+	assert.Contains(t, invokes, "go.uber.org/fx_test.TestGraphSnapshot.func2")
+	assert.Contains(t, invokes, "go.uber.org/fx_test.runGraphServer")
+	assert.Contains(t, invokes, "go.uber.org/fx_test.TestGraphSnapshot.func3")
+	assert.Len(t, invokes, 4, "expected the invoke added by Populate in %v", invokes)
+
+	t.Run("When", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.When(func(fx.Graph) bool { return true }),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing type: fx.Graph")
+	})
+}
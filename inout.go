@@ -98,6 +98,47 @@ import "go.uber.org/dig"
 // The optional tag also allows adding new dependencies without breaking
 // existing consumers of the constructor.
 //
+// Environment Variables
+//
+// Fields of a parameter struct may be read from environment variables instead
+// of the container with the `env:".."` tag. Fx looks up the variable when the
+// constructor is called and converts it to the type of the field. Strings,
+// booleans, integers, floating point numbers, and time.Duration are
+// supported.
+//
+//   type ServerParams struct {
+//     fx.In
+//
+//     Logger  *log.Logger
+//     Port    int           `env:"PORT" default:"8080"`
+//     Host    string        `env:"HOST"`
+//     Timeout time.Duration `env:"TIMEOUT" optional:"true"`
+//   }
+//
+// The `default:".."` tag specifies a value to use if the variable is unset,
+// which may be empty. Variables without a default are required unless the field is also tagged
+// with `optional:"true"`, in which case the constructor receives the field's
+// zero value. If any required variables are missing, the constructor is not
+// called and Fx reports all the missing variables in a single error.
+//
+// Lazy Dependencies
+//
+// Some dependencies are expensive to build and only needed on some code
+// paths. Functions may request a func() (T, error) instead of T to build
+// the value on demand. The value is built the first time the function is
+// called, and the same value is returned on every call after that.
+//
+//   type AdminParams struct {
+//     fx.In
+//
+//     Client func() (*admin.Client, error) `name:"admin"`
+//   }
+//
+// Fx verifies that T can be built when the function requesting it is
+// called, and fails if it can't, unless the field is tagged with
+// `optional:"true"`, in which case the field is left nil. If a func()
+// (T, error) was provided to the container directly, it's used instead.
+//
 // Named Values
 //
 // Some use cases require the application container to hold multiple values of
@@ -160,8 +201,19 @@ import "go.uber.org/dig"
 //     return server
 //   }
 //
-// Note that values in a value group are unordered. Fx makes no guarantees
-// about the order in which these values will be produced.
+// Note that values in a value group are unordered unless they were given an
+// order when they were produced; see the documentation for the Out type. Fx
+// makes no guarantees about the order in which these values will be
+// produced.
+//
+// Values given a key when they were produced may also be requested as a map
+// from keys to values. Values without a key are left out of the map.
+//
+//   type ServerParams struct {
+//     fx.In
+//
+//     Handlers map[string]Handler `group:"server"`
+//   }
 //
 // Unexported fields
 //
@@ -285,4 +337,28 @@ type In = dig.In
 //     Handler []int `group:"server"`         // Consume as [][]int
 //     Handler []int `group:"server,flatten"` // Consume as []int
 //   }
+//
+// To control where values appear in their group, add an `order:".."` tag
+// with an integer. Consumers receive the values of a group sorted by
+// ascending order, with values that have none at order 0. Values with the
+// same order keep the order in which they were provided. See also
+// Annotated.Order.
+//
+//   type MiddlewareResult struct {
+//     fx.Out
+//
+//     Auth    Middleware `group:"middleware" order:"-10"` // runs first
+//     Metrics Middleware `group:"middleware" order:"10"`
+//   }
+//
+// Similarly, a `key:".."` tag identifies a value in its group, so that
+// consumers may request the group as a map. Keys must be unique among the
+// values of the same type in a group, and cannot be used with the flatten
+// option. See also Annotated.Key.
+//
+//   type HandlerResult struct {
+//     fx.Out
+//
+//     Handler Handler `group:"server" key:"hello"`
+//   }
 type Out = dig.Out
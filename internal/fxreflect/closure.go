@@ -0,0 +1,50 @@
+// Copyright (c) 2019-2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxreflect
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Closure returns the address of the closure of fn, a non-nil function.
+// All references to a top-level function share the same closure, as do
+// copies of a function value. Unlike the code pointer reported by reflect,
+// it tells apart closures built by the same function literal that capture
+// different variables, like the constructors returned by
+//
+//  func newRoute(path string) func() Route {
+//    return func() Route { ... }
+//  }
+//
+// Closure panics if fn isn't a non-nil function.
+//
+// This relies on the layout of interfaces and function values in the
+// runtime: an interface holding a function stores the function value, a
+// pointer to its closure, in its data word. TestClosure verifies this on
+// every supported version of Go.
+func Closure(fn interface{}) unsafe.Pointer {
+	if v := reflect.ValueOf(fn); v.Kind() != reflect.Func || v.IsNil() {
+		panic(fmt.Sprintf("fxreflect.Closure expected a non-nil function, got %T", fn))
+	}
+	return (*[2]unsafe.Pointer)(unsafe.Pointer(&fn))[1]
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2019-2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxreflect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closureReceiver struct{ name string }
+
+func (r *closureReceiver) Name() string { return r.name }
+
+func newClosure(name string) func() string {
+	return func() string { return name }
+}
+
+func TestClosure(t *testing.T) {
+	t.Run("TopLevelFunction", func(t *testing.T) {
+		assert.Equal(t, Closure(someFunc), Closure(someFunc))
+		assert.NotEqual(t, Closure(someFunc), Closure(TestClosure))
+	})
+
+	t.Run("Copies", func(t *testing.T) {
+		a := newClosure("a")
+		b := a
+		assert.Equal(t, Closure(a), Closure(b))
+	})
+
+	t.Run("SameLiteral", func(t *testing.T) {
+		assert.NotEqual(t, Closure(newClosure("a")), Closure(newClosure("b")))
+	})
+
+	t.Run("MethodValues", func(t *testing.T) {
+		a := &closureReceiver{name: "a"}
+		b := &closureReceiver{name: "b"}
+		aName := a.Name
+		assert.Equal(t, Closure(aName), Closure(aName))
+		assert.NotEqual(t, Closure(a.Name), Closure(b.Name))
+	})
+
+	t.Run("NotAFunction", func(t *testing.T) {
+		assert.Panics(t, func() { Closure(42) })
+		assert.Panics(t, func() { Closure((func())(nil)) })
+	})
+}
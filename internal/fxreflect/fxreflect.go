@@ -62,6 +62,24 @@ func FuncName(fn interface{}) string {
 	return fmt.Sprintf("%s()", sanitize(function))
 }
 
+// SplitFuncName returns the path of the package of the given function and
+// the name of the function within that package, for example,
+// "go.uber.org/fx" and "New".
+func SplitFuncName(fn interface{}) (pkg, name string) {
+	fnV := reflect.ValueOf(fn)
+	if fnV.Kind() != reflect.Func {
+		return "", fmt.Sprint(fn)
+	}
+
+	function := sanitize(runtime.FuncForPC(fnV.Pointer()).Name())
+	// Everything up to the first "." after the last "/" is the package.
+	idx := strings.LastIndex(function, "/") + 1
+	if i := strings.Index(function[idx:], "."); i >= 0 {
+		return function[:idx+i], function[idx+i+1:]
+	}
+	return "", function
+}
+
 // Ascend the call stack until we leave the Fx production code. This allows us
 // to avoid hard-coding a frame skip, which makes this code work well even
 // when it's wrapped.
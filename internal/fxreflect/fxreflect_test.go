@@ -58,6 +58,16 @@ func TestFuncName(t *testing.T) {
 	}
 }
 
+func TestSplitFuncName(t *testing.T) {
+	pkg, name := SplitFuncName(someFunc)
+	assert.Equal(t, "go.uber.org/fx/internal/fxreflect", pkg)
+	assert.Equal(t, "someFunc", name)
+
+	pkg, name = SplitFuncName(func() {})
+	assert.Equal(t, "go.uber.org/fx/internal/fxreflect", pkg)
+	assert.Equal(t, "TestSplitFuncName.func1", name)
+}
+
 func TestSanitizeFuncNames(t *testing.T) {
 	cases := []struct {
 		name     string
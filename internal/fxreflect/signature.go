@@ -0,0 +1,207 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxreflect
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.uber.org/dig"
+)
+
+var _typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+
+// Key identifies a value in the container by its type and, optionally, the
+// name or value group it belongs to.
+//
+// For value groups, Type is the type of the individual values in the group
+// rather than the slice type consumers request.
+type Key struct {
+	Type  reflect.Type
+	Name  string
+	Group string
+}
+
+// String returns the same representation of the key as dig uses in its
+// messages. For example,
+//
+//  *bytes.Buffer
+//  *bytes.Buffer[name = "foo"]
+//  *bytes.Buffer[group = "bar"]
+func (k Key) String() string {
+	switch {
+	case len(k.Name) > 0:
+		return fmt.Sprintf("%v[name = %q]", k.Type, k.Name)
+	case len(k.Group) > 0:
+		return fmt.Sprintf("%v[group = %q]", k.Type, k.Group)
+	default:
+		return k.Type.String()
+	}
+}
+
+// Param is a single dependency of a function.
+type Param struct {
+	Key
+
+	Optional bool
+
+	// Lazy is true if Key refers to a function of the form
+	// func() (T, error). Unless such a function is provided, Fx builds one
+	// that builds T on demand, and the parameter depends on T instead. See
+	// LazyKey.
+	Lazy bool
+}
+
+// LazyKey returns the key of the value built by the function that a lazy
+// parameter requests.
+func (p Param) LazyKey() Key {
+	elem, _ := LazyType(p.Type)
+	return Key{Type: elem, Name: p.Name}
+}
+
+// LazyType reports whether t is the type of a function that builds a value
+// on demand, func() (T, error), and returns T if it is.
+func LazyType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Func || t.NumIn() != 0 || t.NumOut() != 2 || t.Out(1) != _typeOfError {
+		return nil, false
+	}
+	return t.Out(0), true
+}
+
+// Signature describes what a function consumes from and produces into the
+// container, after expanding parameter and result structs.
+type Signature struct {
+	Params  []Param
+	Results []Key
+
+	// Orders holds the order of each result in its value group, as given
+	// by the order tag of fx.Out fields, or 0 if it has none.
+	Orders []int
+
+	// GroupKeys holds the key of each result in its value group, as given
+	// by the key tag of fx.Out fields, or "" if it has none.
+	GroupKeys []string
+}
+
+// InspectSignature inspects the given function and reports the values it
+// depends on and the values it produces, following the same rules as dig.
+// An empty Signature is returned if fn is not a function.
+//
+// Variadic arguments and error results are ignored. Parameters of the form
+// func() (T, error) are reported as lazy.
+func InspectSignature(fn interface{}) Signature {
+	var sig Signature
+
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return sig
+	}
+
+	numIn := t.NumIn()
+	if t.IsVariadic() {
+		numIn--
+	}
+	for i := 0; i < numIn; i++ {
+		sig.Params = appendParams(sig.Params, t.In(i), reflect.StructTag(""))
+	}
+
+	for i := 0; i < t.NumOut(); i++ {
+		sig = appendResults(sig, t.Out(i), reflect.StructTag(""))
+	}
+
+	return sig
+}
+
+func appendParams(params []Param, t reflect.Type, tag reflect.StructTag) []Param {
+	if t.Kind() == reflect.Struct && dig.IsIn(t) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || (f.Anonymous && f.Type == reflect.TypeOf(dig.In{})) {
+				continue
+			}
+			params = appendParams(params, f.Type, f.Tag)
+		}
+		return params
+	}
+
+	optional, _ := strconv.ParseBool(tag.Get("optional"))
+	// Value groups may be consumed as slices, or as maps of the values
+	// given a key.
+	if g := tag.Get("group"); len(g) > 0 && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map) {
+		return append(params, Param{
+			Key: Key{Type: t.Elem(), Group: g},
+		})
+	}
+
+	_, lazy := LazyType(t)
+	return append(params, Param{
+		Key:      Key{Type: t, Name: tag.Get("name")},
+		Optional: optional,
+		Lazy:     lazy,
+	})
+}
+
+func appendResults(sig Signature, t reflect.Type, tag reflect.StructTag) Signature {
+	if t == _typeOfError {
+		return sig
+	}
+
+	if t.Kind() == reflect.Struct && dig.IsOut(t) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || (f.Anonymous && f.Type == reflect.TypeOf(dig.Out{})) {
+				continue
+			}
+			sig = appendResults(sig, f.Type, f.Tag)
+		}
+		return sig
+	}
+
+	k := ResultKey(t, tag.Get("name"), tag.Get("group"))
+	order, _ := strconv.Atoi(tag.Get("order"))
+	key := tag.Get("key")
+	if len(k.Group) == 0 {
+		order, key = 0, ""
+	}
+	sig.Results = append(sig.Results, k)
+	sig.Orders = append(sig.Orders, order)
+	sig.GroupKeys = append(sig.GroupKeys, key)
+	return sig
+}
+
+// ResultKey builds the key under which a value of type t is produced with
+// the given name or group. The group may include the ",flatten" option, in
+// which case the key refers to the elements of the slice t.
+func ResultKey(t reflect.Type, name, group string) Key {
+	if len(group) == 0 {
+		return Key{Type: t, Name: name}
+	}
+
+	g := strings.Split(group, ",")
+	for _, opt := range g[1:] {
+		if opt == "flatten" && t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+	}
+	return Key{Type: t, Group: g[0]}
+}
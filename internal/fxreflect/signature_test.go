@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxreflect
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/dig"
+)
+
+func TestInspectSignature(t *testing.T) {
+	var (
+		typeOfBuffer = reflect.TypeOf(&bytes.Buffer{})
+		typeOfReader = reflect.TypeOf((*io.Reader)(nil)).Elem()
+		typeOfWriter = reflect.TypeOf((*io.Writer)(nil)).Elem()
+	)
+
+	type params struct {
+		dig.In
+
+		Reader  io.Reader            `name:"r"`
+		Writer  io.Writer            `optional:"true"`
+		Writers []io.Writer          `group:"writers"`
+		ByName  map[string]io.Writer `group:"writers"`
+	}
+
+	type results struct {
+		dig.Out
+
+		Reader  io.Reader   `name:"r" order:"1"`
+		Writers []io.Writer `group:"writers,flatten" order:"-5"`
+		Writer  io.Writer   `group:"writers" key:"w"`
+	}
+
+	tests := []struct {
+		desc string
+		give interface{}
+		want Signature
+	}{
+		{
+			desc: "not a function",
+			give: 42,
+		},
+		{
+			desc: "simple",
+			give: func(io.Reader, ...io.Writer) (*bytes.Buffer, error) { return nil, nil },
+			want: Signature{
+				Params:    []Param{{Key: Key{Type: typeOfReader}}},
+				Results:   []Key{{Type: typeOfBuffer}},
+				Orders:    []int{0},
+				GroupKeys: []string{""},
+			},
+		},
+		{
+			desc: "parameter struct",
+			give: func(params) {},
+			want: Signature{
+				Params: []Param{
+					{Key: Key{Type: typeOfReader, Name: "r"}},
+					{Key: Key{Type: typeOfWriter}, Optional: true},
+					{Key: Key{Type: typeOfWriter, Group: "writers"}},
+					{Key: Key{Type: typeOfWriter, Group: "writers"}},
+				},
+			},
+		},
+		{
+			desc: "lazy",
+			give: func(func() (io.Reader, error), struct {
+				dig.In
+
+				Writer func() (io.Writer, error) `name:"w"`
+			}) {
+			},
+			want: Signature{
+				Params: []Param{
+					{Key: Key{Type: reflect.TypeOf((func() (io.Reader, error))(nil))}, Lazy: true},
+					{Key: Key{Type: reflect.TypeOf((func() (io.Writer, error))(nil)), Name: "w"}, Lazy: true},
+				},
+			},
+		},
+		{
+			desc: "result struct",
+			give: func() (results, error) { return results{}, nil },
+			want: Signature{
+				Results: []Key{
+					{Type: typeOfReader, Name: "r"},
+					{Type: typeOfWriter, Group: "writers"},
+					{Type: typeOfWriter, Group: "writers"},
+				},
+				Orders:    []int{0, -5, 0},
+				GroupKeys: []string{"", "", "w"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, InspectSignature(tt.give))
+		})
+	}
+}
+
+func TestParamLazyKey(t *testing.T) {
+	p := Param{
+		Key:  Key{Type: reflect.TypeOf((func() (io.Reader, error))(nil)), Name: "r"},
+		Lazy: true,
+	}
+	assert.Equal(t, Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem(), Name: "r"}, p.LazyKey())
+}
+
+func TestKeyString(t *testing.T) {
+	typ := reflect.TypeOf(&bytes.Buffer{})
+
+	assert.Equal(t, "*bytes.Buffer", Key{Type: typ}.String())
+	assert.Equal(t, `*bytes.Buffer[name = "foo"]`, Key{Type: typ, Name: "foo"}.String())
+	assert.Equal(t, `*bytes.Buffer[group = "bar"]`, Key{Type: typ, Group: "bar"}.String())
+}
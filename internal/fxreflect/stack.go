@@ -112,13 +112,22 @@ func (fs Stack) Format(w fmt.State, c rune) {
 // CallerName returns the name of the first caller in this stack that isn't
 // owned by the Fx library.
 func (fs Stack) CallerName() string {
+	if f, ok := fs.Caller(); ok {
+		return f.Function
+	}
+	return "n/a"
+}
+
+// Caller returns the first frame in this stack that isn't owned by the Fx
+// library. It returns false if there is no such frame.
+func (fs Stack) Caller() (Frame, bool) {
 	for _, f := range fs {
 		if shouldIgnoreFrame(f) {
 			continue
 		}
-		return f.Function
+		return f, true
 	}
-	return "n/a"
+	return Frame{}, false
 }
 
 // CallerStack returns the call stack for the calling function, up to depth frames
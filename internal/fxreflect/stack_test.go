@@ -154,6 +154,22 @@ func TestStackCallerName(t *testing.T) {
 	}
 }
 
+func TestStackCaller(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		_, ok := Stack{}.Caller()
+		assert.False(t, ok)
+	})
+
+	t.Run("skip Fx components", func(t *testing.T) {
+		f, ok := Stack{
+			{Function: "go.uber.org/fx.Foo()", File: "go.uber.org/fx/foo.go"},
+			{Function: "foo/bar.Baz()", File: "foo/bar/baz.go", Line: 42},
+		}.Caller()
+		assert.True(t, ok)
+		assert.Equal(t, Frame{Function: "foo/bar.Baz()", File: "foo/bar/baz.go", Line: 42}, f)
+	})
+}
+
 func TestFrameString(t *testing.T) {
 	tests := []struct {
 		desc string
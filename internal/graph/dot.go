@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// WriteDOT draws the constructors of the graph in the DOT language, laid
+// out the same way as the visualizations of dig. If failed is not empty,
+// it lists the values on the path to a failure, with the value that caused
+// it last: that value is drawn in red and the others in orange.
+func (g *Graph) WriteDOT(w io.Writer, failed []fxreflect.Key) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph {")
+	fmt.Fprintln(bw, "\trankdir=RL;")
+	fmt.Fprintln(bw, "\tgraph [compound=true];")
+
+	// Groups point to the values added to them.
+	var groups []fxreflect.Key
+	members := make(map[fxreflect.Key][]string)
+	for _, n := range g.Constructors {
+		for _, k := range n.Results {
+			if len(k.Group) == 0 {
+				continue
+			}
+			if _, ok := members[k]; !ok {
+				groups = append(groups, k)
+			}
+			members[k] = append(members[k], fmt.Sprintf("%v%d", dotID(k), len(members[k])))
+		}
+	}
+	for _, group := range groups {
+		fmt.Fprintf(bw, "\t%q [shape=diamond %v];\n", dotGroupID(group), dotLabel(group))
+		for _, m := range members[group] {
+			fmt.Fprintf(bw, "\t%q -> %q;\n", dotGroupID(group), m)
+		}
+	}
+
+	indexes := make(map[fxreflect.Key]int)
+	for i, n := range g.Constructors {
+		pkg, name := fxreflect.SplitFuncName(n.Func)
+		fmt.Fprintf(bw, "\tsubgraph cluster_%d {\n", i)
+		if len(pkg) > 0 {
+			fmt.Fprintf(bw, "\t\tlabel = %q;\n", pkg)
+		}
+		fmt.Fprintf(bw, "\t\tconstructor_%d [shape=plaintext label=%q];\n", i, name)
+		for _, k := range n.Results {
+			id := dotID(k)
+			if len(k.Group) > 0 {
+				id = fmt.Sprintf("%v%d", id, indexes[k])
+				indexes[k]++
+			}
+			fmt.Fprintf(bw, "\t\t%q [%v];\n", id, dotLabel(k))
+		}
+		fmt.Fprintln(bw, "\t}")
+
+		for _, p := range n.Params {
+			k, lazy := g.Dependency(p)
+			switch {
+			case len(k.Group) > 0:
+				fmt.Fprintf(bw, "\tconstructor_%d -> %q [ltail=cluster_%d];\n", i, dotGroupID(k), i)
+			case p.Optional || lazy:
+				fmt.Fprintf(bw, "\tconstructor_%d -> %q [ltail=cluster_%d style=dashed];\n", i, dotID(k), i)
+			default:
+				fmt.Fprintf(bw, "\tconstructor_%d -> %q [ltail=cluster_%d];\n", i, dotID(k), i)
+			}
+		}
+	}
+
+	for i, k := range failed {
+		id, color := dotID(k), "orange"
+		if len(k.Group) > 0 {
+			id = dotGroupID(k)
+		}
+		if i == len(failed)-1 {
+			color = "red"
+		}
+		fmt.Fprintf(bw, "\t%q [color=%v];\n", id, color)
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotID identifies the value with the given key in a DOT graph. Values of
+// groups are suffixed with their index in the group.
+func dotID(k fxreflect.Key) string {
+	switch {
+	case len(k.Name) > 0:
+		return fmt.Sprintf("%v[name=%v]", k.Type, k.Name)
+	case len(k.Group) > 0:
+		return fmt.Sprintf("%v[group=%v]", k.Type, k.Group)
+	default:
+		return k.Type.String()
+	}
+}
+
+// dotGroupID identifies the group with the given key in a DOT graph.
+func dotGroupID(k fxreflect.Key) string {
+	return fmt.Sprintf("[type=%v group=%v]", k.Type, k.Group)
+}
+
+// dotLabel returns the label attribute of the value with the given key in
+// a DOT graph.
+func dotLabel(k fxreflect.Key) string {
+	switch {
+	case len(k.Name) > 0:
+		return fmt.Sprintf(`label=<%v<BR /><FONT POINT-SIZE="10">Name: %v</FONT>>`, k.Type, k.Name)
+	case len(k.Group) > 0:
+		return fmt.Sprintf(`label=<%v<BR /><FONT POINT-SIZE="10">Group: %v</FONT>>`, k.Type, k.Group)
+	default:
+		return fmt.Sprintf(`label=<%v>`, k.Type)
+	}
+}
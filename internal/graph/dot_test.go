@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+func newDOTBuffer() *bytes.Buffer { return nil }
+
+func newDOTReader(*bytes.Buffer) io.Reader { return nil }
+
+func TestWriteDOT(t *testing.T) {
+	var (
+		buffer = fxreflect.Key{Type: reflect.TypeOf(&bytes.Buffer{})}
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem(), Name: "in"}
+		group  = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem(), Group: "readers"}
+	)
+
+	g := New()
+	g.AddConstructor(&Node{
+		Func:    newDOTBuffer,
+		Results: []fxreflect.Key{buffer},
+	})
+	g.AddConstructor(&Node{
+		Func:    newDOTReader,
+		Params:  []fxreflect.Param{{Key: buffer}},
+		Results: []fxreflect.Key{reader, group},
+	})
+	g.AddInvoke(&Node{
+		Params: []fxreflect.Param{{Key: group}},
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, g.WriteDOT(&b, nil))
+		got := b.String()
+
+		assert.True(t, strings.HasPrefix(got, "digraph {\n"), got)
+		for _, want := range []string{
+			`"[type=io.Reader group=readers]" [shape=diamond label=<io.Reader<BR /><FONT POINT-SIZE="10">Group: readers</FONT>>];`,
+			`"[type=io.Reader group=readers]" -> "io.Reader[group=readers]0";`,
+			`label = "go.uber.org/fx/internal/graph";`,
+			`constructor_0 [shape=plaintext label="newDOTBuffer"];`,
+			`"*bytes.Buffer" [label=<*bytes.Buffer>];`,
+			`"io.Reader[name=in]" [label=<io.Reader<BR /><FONT POINT-SIZE="10">Name: in</FONT>>];`,
+			`"io.Reader[group=readers]0" [label=<io.Reader<BR /><FONT POINT-SIZE="10">Group: readers</FONT>>];`,
+			`constructor_1 -> "*bytes.Buffer" [ltail=cluster_1];`,
+		} {
+			assert.Contains(t, got, want)
+		}
+		assert.NotContains(t, got, "color=")
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, g.WriteDOT(&b, []fxreflect.Key{group, buffer}))
+		got := b.String()
+
+		assert.Contains(t, got, `"[type=io.Reader group=readers]" [color=orange];`)
+		assert.Contains(t, got, `"*bytes.Buffer" [color=red];`)
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package graph keeps track of the functions an Fx application feeds to its
+// container, and what each of them consumes and produces.
+package graph
+
+import (
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// Node is a function known to the container: either a constructor or an
+// invoked function.
+type Node struct {
+	// Function as provided or invoked by the user. For annotated
+	// constructors, this is the annotation's target.
+	Func interface{}
+
+	// Stack trace of where this function was provided or invoked.
+	Stack fxreflect.Stack
+
+	// Values requested from the container by this function.
+	Params []fxreflect.Param
+
+	// Values produced by this function. Always empty for invokes.
+	Results []fxreflect.Key
+
+	// Order of each of the values produced by this function in its value
+	// group. Nil if none of them were given an order.
+	Orders []int
+
+	// Key of each of the values produced by this function in its value
+	// group. Nil if none of them were given a key.
+	GroupKeys []string
+
+	// Builtin is true for constructors provided by Fx itself.
+	Builtin bool
+}
+
+// Graph records the constructors and invocations of an Fx application.
+type Graph struct {
+	Constructors []*Node
+	Invokes      []*Node
+
+	// Constructors that may be called at any time after the application
+	// was built, like request-scoped constructors. Their results are not
+	// provided to the application.
+	Scoped []*Node
+
+	providers map[fxreflect.Key][]*Node
+}
+
+// New builds an empty Graph.
+func New() *Graph {
+	return &Graph{providers: make(map[fxreflect.Key][]*Node)}
+}
+
+// AddConstructor records a constructor that was successfully provided to
+// the container.
+func (g *Graph) AddConstructor(n *Node) {
+	g.Constructors = append(g.Constructors, n)
+	for _, k := range n.Results {
+		g.providers[k] = append(g.providers[k], n)
+	}
+}
+
+// AddInvoke records a function that will be invoked.
+func (g *Graph) AddInvoke(n *Node) {
+	g.Invokes = append(g.Invokes, n)
+}
+
+// AddScoped records a constructor that may be called at any time after the
+// application was built. The values it depends on are never unused.
+func (g *Graph) AddScoped(n *Node) {
+	g.Scoped = append(g.Scoped, n)
+}
+
+// Providers returns the constructors that produce the given key.
+func (g *Graph) Providers(k fxreflect.Key) []*Node {
+	return g.providers[k]
+}
+
+// Dependency returns the key of the value that the given parameter depends
+// on, and whether it's built on demand. Lazy parameters depend on the value
+// built by the function they request, unless that function is provided.
+func (g *Graph) Dependency(p fxreflect.Param) (k fxreflect.Key, lazy bool) {
+	if p.Lazy && len(g.providers[p.Key]) == 0 {
+		return p.LazyKey(), true
+	}
+	return p.Key, false
+}
+
+// Unused returns the constructors, other than builtin ones, whose results
+// are never requested by an invoked function, directly or through other
+// constructors.
+func (g *Graph) Unused() []*Node {
+	used := make(map[*Node]struct{})
+	var visit func(*Node)
+	visit = func(n *Node) {
+		for _, p := range n.Params {
+			k, _ := g.Dependency(p)
+			for _, provider := range g.providers[k] {
+				if _, ok := used[provider]; ok {
+					continue
+				}
+				used[provider] = struct{}{}
+				visit(provider)
+			}
+		}
+	}
+	for _, ns := range [][]*Node{g.Invokes, g.Scoped} {
+		for _, n := range ns {
+			visit(n)
+		}
+	}
+
+	var unused []*Node
+	for _, n := range g.Constructors {
+		if _, ok := used[n]; !ok && !n.Builtin {
+			unused = append(unused, n)
+		}
+	}
+	return unused
+}
+
+// Sorted returns the constructors in dependency order: each constructor
+// comes after the constructors that provide its parameters. Constructors
+// that don't depend on each other keep the order they were added in.
+func (g *Graph) Sorted() []*Node {
+	visited := make(map[*Node]struct{}, len(g.Constructors))
+	sorted := make([]*Node, 0, len(g.Constructors))
+
+	var visit func(*Node)
+	visit = func(n *Node) {
+		if _, ok := visited[n]; ok {
+			return
+		}
+		visited[n] = struct{}{}
+
+		for _, p := range n.Params {
+			k, _ := g.Dependency(p)
+			for _, provider := range g.providers[k] {
+				visit(provider)
+			}
+		}
+		sorted = append(sorted, n)
+	}
+	for _, n := range g.Constructors {
+		visit(n)
+	}
+	return sorted
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/goleak"
+)
+
+func TestGraph(t *testing.T) {
+	var (
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		writer = fxreflect.Key{Type: reflect.TypeOf((*io.Writer)(nil)).Elem()}
+	)
+
+	newReader := &Node{Results: []fxreflect.Key{reader}}
+	newWriter := &Node{
+		Params:  []fxreflect.Param{{Key: reader}},
+		Results: []fxreflect.Key{writer},
+	}
+	run := &Node{Params: []fxreflect.Param{{Key: reader}, {Key: writer}}}
+
+	g := New()
+	g.AddConstructor(newReader)
+	g.AddConstructor(newWriter)
+	g.AddInvoke(run)
+
+	assert.Equal(t, []*Node{newReader, newWriter}, g.Constructors)
+	assert.Equal(t, []*Node{run}, g.Invokes)
+
+	assert.Equal(t, []*Node{newReader}, g.Providers(reader))
+	assert.Equal(t, []*Node{newWriter}, g.Providers(writer))
+	assert.Empty(t, g.Providers(fxreflect.Key{Type: reader.Type, Name: "foo"}))
+}
+
+func TestUnused(t *testing.T) {
+	var (
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		writer = fxreflect.Key{Type: reflect.TypeOf((*io.Writer)(nil)).Elem()}
+		closer = fxreflect.Key{Type: reflect.TypeOf((*io.Closer)(nil)).Elem()}
+	)
+
+	newReader := &Node{Results: []fxreflect.Key{reader}}
+	newWriter := &Node{
+		Params:  []fxreflect.Param{{Key: reader}},
+		Results: []fxreflect.Key{writer},
+	}
+	newCloser := &Node{Results: []fxreflect.Key{closer}}
+	newBuiltin := &Node{Results: []fxreflect.Key{{Type: reader.Type, Name: "builtin"}}, Builtin: true}
+	run := &Node{Params: []fxreflect.Param{{Key: writer}}}
+
+	g := New()
+	g.AddConstructor(newReader)
+	g.AddConstructor(newWriter)
+	g.AddConstructor(newCloser)
+	g.AddConstructor(newBuiltin)
+	g.AddInvoke(run)
+
+	assert.Equal(t, []*Node{newCloser}, g.Unused())
+}
+
+func TestDependency(t *testing.T) {
+	var (
+		reader    = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		writer    = fxreflect.Key{Type: reflect.TypeOf((*io.Writer)(nil)).Elem()}
+		getReader = fxreflect.Param{
+			Key:  fxreflect.Key{Type: reflect.TypeOf((func() (io.Reader, error))(nil))},
+			Lazy: true,
+		}
+		getWriter = fxreflect.Param{
+			Key:  fxreflect.Key{Type: reflect.TypeOf((func() (io.Writer, error))(nil))},
+			Lazy: true,
+		}
+	)
+
+	newReader := &Node{Results: []fxreflect.Key{reader}}
+	newGetWriter := &Node{Results: []fxreflect.Key{getWriter.Key}}
+	run := &Node{Params: []fxreflect.Param{getReader, getWriter}}
+
+	g := New()
+	g.AddConstructor(newReader)
+	g.AddConstructor(newGetWriter)
+	g.AddInvoke(run)
+
+	t.Run("NotProvided", func(t *testing.T) {
+		k, lazy := g.Dependency(getReader)
+		assert.Equal(t, reader, k)
+		assert.True(t, lazy)
+	})
+
+	t.Run("Provided", func(t *testing.T) {
+		k, lazy := g.Dependency(getWriter)
+		assert.Equal(t, getWriter.Key, k)
+		assert.False(t, lazy)
+	})
+
+	t.Run("NotLazy", func(t *testing.T) {
+		k, lazy := g.Dependency(fxreflect.Param{Key: writer})
+		assert.Equal(t, writer, k)
+		assert.False(t, lazy)
+	})
+
+	assert.Empty(t, g.Unused())
+}
+
+func TestSorted(t *testing.T) {
+	var (
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		writer = fxreflect.Key{Type: reflect.TypeOf((*io.Writer)(nil)).Elem()}
+		closer = fxreflect.Key{Type: reflect.TypeOf((*io.Closer)(nil)).Elem()}
+	)
+
+	newCloser := &Node{
+		Params:  []fxreflect.Param{{Key: writer}},
+		Results: []fxreflect.Key{closer},
+	}
+	newWriter := &Node{
+		Params:  []fxreflect.Param{{Key: reader}},
+		Results: []fxreflect.Key{writer},
+	}
+	newReader := &Node{Results: []fxreflect.Key{reader}}
+	other := &Node{}
+
+	g := New()
+	g.AddConstructor(newCloser)
+	g.AddConstructor(other)
+	g.AddConstructor(newWriter)
+	g.AddConstructor(newReader)
+
+	assert.Equal(t, []*Node{newReader, newWriter, newCloser, other}, g.Sorted())
+}
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
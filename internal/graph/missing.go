@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// Step is a single step on the path from a function to a value that is
+// missing from the container.
+type Step struct {
+	// Key requested by the previous step, or by the function that the path
+	// starts at.
+	Key fxreflect.Key
+
+	// Provider of Key. Nil for the last step of the path.
+	Provider *Node
+}
+
+// MissingPath finds a required value that is transitively requested by
+// the given function but that no constructor provides. It returns the
+// path from the function to the missing value, whose last step is the
+// missing value, or false if every required value is provided.
+func (g *Graph) MissingPath(n *Node) ([]Step, bool) {
+	return g.missingPath(n, make(map[*Node]struct{}))
+}
+
+func (g *Graph) missingPath(n *Node, visited map[*Node]struct{}) ([]Step, bool) {
+	if _, ok := visited[n]; ok {
+		return nil, false
+	}
+	visited[n] = struct{}{}
+
+	// Like the container, report values missing for n itself before
+	// looking at the functions that n depends on.
+	for _, p := range n.Params {
+		k, _ := g.Dependency(p)
+		// Value groups may always be empty.
+		if len(g.providers[k]) == 0 && !p.Optional && len(p.Group) == 0 {
+			return []Step{{Key: k}}, true
+		}
+	}
+
+	for _, p := range n.Params {
+		k, _ := g.Dependency(p)
+		for _, provider := range g.providers[k] {
+			if path, ok := g.missingPath(provider, visited); ok {
+				return append([]Step{{Key: k, Provider: provider}}, path...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Candidate is a value that is provided to the container and that looks
+// similar to one that is missing.
+type Candidate struct {
+	// Key of the provided value.
+	Key fxreflect.Key
+
+	// Provider of Key.
+	Provider *Node
+
+	// Reason describes how Key differs from the missing value.
+	Reason string
+}
+
+// NearMisses returns values that are provided to the container and that
+// resemble the given key: the same type as a pointer or a value, the same
+// type with a different name or group, or a type with the same name in a
+// different package. Candidates are reported in the order their
+// constructors were added.
+func (g *Graph) NearMisses(k fxreflect.Key) []Candidate {
+	var candidates []Candidate
+	for _, n := range g.Constructors {
+		for _, result := range n.Results {
+			if reason, ok := nearMiss(k, result); ok {
+				candidates = append(candidates, Candidate{
+					Key:      result,
+					Provider: n,
+					Reason:   reason,
+				})
+			}
+		}
+	}
+	return candidates
+}
+
+// nearMiss reports whether got resembles want, and if so, how they differ.
+func nearMiss(want, got fxreflect.Key) (string, bool) {
+	sameAnnotations := want.Name == got.Name && want.Group == got.Group
+
+	switch {
+	case want.Type == got.Type:
+		if sameAnnotations {
+			return "", false
+		}
+		switch {
+		case len(got.Name) > 0:
+			return fmt.Sprintf("named %q", got.Name), true
+		case len(got.Group) > 0:
+			return fmt.Sprintf("in group %q", got.Group), true
+		default:
+			return "without a name or group", true
+		}
+
+	case !sameAnnotations:
+		return "", false
+
+	case got.Type.Kind() == reflect.Ptr && got.Type.Elem() == want.Type:
+		return "a pointer, not a value", true
+
+	case want.Type.Kind() == reflect.Ptr && want.Type.Elem() == got.Type:
+		return "a value, not a pointer", true
+	}
+
+	wantBase, gotBase := indirect(want.Type), indirect(got.Type)
+	if len(wantBase.Name()) > 0 && wantBase.Name() == gotBase.Name() &&
+		wantBase.PkgPath() != gotBase.PkgPath() {
+		return fmt.Sprintf("from package %q", gotBase.PkgPath()), true
+	}
+	return "", false
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
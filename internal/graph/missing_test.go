@@ -0,0 +1,144 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+func TestMissingPath(t *testing.T) {
+	var (
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		writer = fxreflect.Key{Type: reflect.TypeOf((*io.Writer)(nil)).Elem()}
+		buffer = fxreflect.Key{Type: reflect.TypeOf(&bytes.Buffer{})}
+	)
+
+	t.Run("Found", func(t *testing.T) {
+		newReader := &Node{
+			Params:  []fxreflect.Param{{Key: buffer}},
+			Results: []fxreflect.Key{reader},
+		}
+		run := &Node{Params: []fxreflect.Param{{Key: reader}}}
+
+		g := New()
+		g.AddConstructor(newReader)
+		g.AddInvoke(run)
+
+		path, ok := g.MissingPath(run)
+		assert.True(t, ok)
+		assert.Equal(t, []Step{
+			{Key: reader, Provider: newReader},
+			{Key: buffer},
+		}, path)
+	})
+
+	t.Run("OptionalAndGroups", func(t *testing.T) {
+		run := &Node{Params: []fxreflect.Param{
+			{Key: reader, Optional: true},
+			{Key: fxreflect.Key{Type: writer.Type, Group: "writers"}},
+		}}
+
+		g := New()
+		g.AddInvoke(run)
+
+		_, ok := g.MissingPath(run)
+		assert.False(t, ok)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		newReader := &Node{
+			Params:  []fxreflect.Param{{Key: writer}},
+			Results: []fxreflect.Key{reader},
+		}
+		newWriter := &Node{
+			Params:  []fxreflect.Param{{Key: reader}},
+			Results: []fxreflect.Key{writer},
+		}
+		run := &Node{Params: []fxreflect.Param{{Key: reader}}}
+
+		g := New()
+		g.AddConstructor(newReader)
+		g.AddConstructor(newWriter)
+		g.AddInvoke(run)
+
+		_, ok := g.MissingPath(run)
+		assert.False(t, ok)
+	})
+
+	t.Run("MissingForFunctionFirst", func(t *testing.T) {
+		// The container reports values missing for the function it
+		// calls before building the values it depends on.
+		newReader := &Node{
+			Params:  []fxreflect.Param{{Key: buffer}},
+			Results: []fxreflect.Key{reader},
+		}
+		run := &Node{Params: []fxreflect.Param{{Key: reader}, {Key: writer}}}
+
+		g := New()
+		g.AddConstructor(newReader)
+		g.AddInvoke(run)
+
+		path, ok := g.MissingPath(run)
+		assert.True(t, ok)
+		assert.Equal(t, []Step{{Key: writer}}, path)
+	})
+}
+
+func TestNearMisses(t *testing.T) {
+	type Buffer struct{}
+
+	var (
+		bufferPtr  = reflect.TypeOf(&bytes.Buffer{})
+		bufferVal  = reflect.TypeOf(bytes.Buffer{})
+		localPtr   = reflect.TypeOf(&Buffer{})
+		builderPtr = reflect.TypeOf(&strings.Builder{})
+	)
+
+	newValue := &Node{Results: []fxreflect.Key{{Type: bufferVal}}}
+	newNamed := &Node{Results: []fxreflect.Key{{Type: bufferPtr, Name: "foo"}}}
+	newGrouped := &Node{Results: []fxreflect.Key{{Type: bufferPtr, Group: "bufs"}}}
+	newLocal := &Node{Results: []fxreflect.Key{{Type: localPtr}}}
+	newBuilder := &Node{Results: []fxreflect.Key{{Type: builderPtr}}}
+
+	g := New()
+	for _, n := range []*Node{newValue, newNamed, newGrouped, newLocal, newBuilder} {
+		g.AddConstructor(n)
+	}
+
+	assert.Equal(t, []Candidate{
+		{Key: fxreflect.Key{Type: bufferVal}, Provider: newValue, Reason: "a value, not a pointer"},
+		{Key: fxreflect.Key{Type: bufferPtr, Name: "foo"}, Provider: newNamed, Reason: `named "foo"`},
+		{Key: fxreflect.Key{Type: bufferPtr, Group: "bufs"}, Provider: newGrouped, Reason: `in group "bufs"`},
+		{Key: fxreflect.Key{Type: localPtr}, Provider: newLocal, Reason: `from package "go.uber.org/fx/internal/graph"`},
+	}, g.NearMisses(fxreflect.Key{Type: bufferPtr}))
+
+	assert.Equal(t, []Candidate{
+		{Key: fxreflect.Key{Type: bufferVal}, Provider: newValue, Reason: "without a name or group"},
+		{Key: fxreflect.Key{Type: bufferPtr, Name: "foo"}, Provider: newNamed, Reason: "a pointer, not a value"},
+	}, g.NearMisses(fxreflect.Key{Type: bufferVal, Name: "foo"}))
+}
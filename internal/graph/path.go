@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+// PathTo finds the path from the given function to the constructor target
+// through the values that the function transitively requests as
+// arguments. The last step of the path is provided by target. It returns
+// false if the function doesn't depend on target. Values built on demand
+// aren't arguments, so they're not followed.
+func (g *Graph) PathTo(n, target *Node) ([]Step, bool) {
+	return g.pathTo(n, target, make(map[*Node]struct{}))
+}
+
+func (g *Graph) pathTo(n, target *Node, visited map[*Node]struct{}) ([]Step, bool) {
+	if _, ok := visited[n]; ok {
+		return nil, false
+	}
+	visited[n] = struct{}{}
+
+	for _, p := range n.Params {
+		k, lazy := g.Dependency(p)
+		if lazy {
+			continue
+		}
+		for _, provider := range g.providers[k] {
+			if provider == target {
+				return []Step{{Key: k, Provider: provider}}, true
+			}
+			if path, ok := g.pathTo(provider, target, visited); ok {
+				return append([]Step{{Key: k, Provider: provider}}, path...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Cycle finds constructors that depend on each other. It returns the
+// values on the cycle along with their providers, starting and ending with
+// the same value, or false if there's no cycle. Values built on demand
+// don't form cycles since they aren't arguments.
+func (g *Graph) Cycle() ([]Step, bool) {
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[*Node]int, len(g.Constructors))
+
+	var path []Step
+	var visit func(*Node) ([]Step, bool)
+	visit = func(n *Node) ([]Step, bool) {
+		state[n] = visiting
+		for _, p := range n.Params {
+			k, lazy := g.Dependency(p)
+			if lazy {
+				continue
+			}
+			for _, provider := range g.providers[k] {
+				path = append(path, Step{Key: k, Provider: provider})
+				switch state[provider] {
+				case visiting:
+					// The cycle starts where provider was first reached.
+					for i, step := range path {
+						if step.Provider == provider {
+							return append([]Step(nil), path[i:]...), true
+						}
+					}
+				case 0:
+					if cycle, ok := visit(provider); ok {
+						return cycle, true
+					}
+				}
+				path = path[:len(path)-1]
+			}
+		}
+		state[n] = done
+		return nil, false
+	}
+
+	for _, n := range g.Constructors {
+		if state[n] != 0 {
+			continue
+		}
+		// Start with a step into n so that the cycle can be found
+		// when it goes through n.
+		for _, k := range n.Results {
+			path = []Step{{Key: k, Provider: n}}
+			break
+		}
+		if cycle, ok := visit(n); ok {
+			return cycle, true
+		}
+	}
+	return nil, false
+}
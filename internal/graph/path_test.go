@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package graph
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+func TestPathTo(t *testing.T) {
+	var (
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		buffer = fxreflect.Key{Type: reflect.TypeOf(&bytes.Buffer{})}
+	)
+
+	newBuffer := &Node{Results: []fxreflect.Key{buffer}}
+	newReader := &Node{
+		Params:  []fxreflect.Param{{Key: buffer}},
+		Results: []fxreflect.Key{reader},
+	}
+	run := &Node{Params: []fxreflect.Param{{Key: reader}}}
+	lazy := &Node{Params: []fxreflect.Param{{
+		Key:  fxreflect.Key{Type: reflect.TypeOf(func() (io.Reader, error) { return nil, nil })},
+		Lazy: true,
+	}}}
+
+	g := New()
+	g.AddConstructor(newBuffer)
+	g.AddConstructor(newReader)
+	g.AddInvoke(run)
+	g.AddInvoke(lazy)
+
+	t.Run("Found", func(t *testing.T) {
+		path, ok := g.PathTo(run, newBuffer)
+		assert.True(t, ok)
+		assert.Equal(t, []Step{
+			{Key: reader, Provider: newReader},
+			{Key: buffer, Provider: newBuffer},
+		}, path)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, ok := g.PathTo(newBuffer, newReader)
+		assert.False(t, ok)
+	})
+
+	t.Run("LazyIsNotFollowed", func(t *testing.T) {
+		_, ok := g.PathTo(lazy, newReader)
+		assert.False(t, ok)
+	})
+}
+
+func TestCycle(t *testing.T) {
+	var (
+		reader = fxreflect.Key{Type: reflect.TypeOf((*io.Reader)(nil)).Elem()}
+		writer = fxreflect.Key{Type: reflect.TypeOf((*io.Writer)(nil)).Elem()}
+		buffer = fxreflect.Key{Type: reflect.TypeOf(&bytes.Buffer{})}
+	)
+
+	t.Run("Found", func(t *testing.T) {
+		newBuffer := &Node{Results: []fxreflect.Key{buffer}}
+		newReader := &Node{
+			Params:  []fxreflect.Param{{Key: buffer}, {Key: writer}},
+			Results: []fxreflect.Key{reader},
+		}
+		newWriter := &Node{
+			Params:  []fxreflect.Param{{Key: reader}},
+			Results: []fxreflect.Key{writer},
+		}
+
+		g := New()
+		g.AddConstructor(newBuffer)
+		g.AddConstructor(newReader)
+		g.AddConstructor(newWriter)
+
+		cycle, ok := g.Cycle()
+		assert.True(t, ok)
+		assert.Equal(t, []Step{
+			{Key: reader, Provider: newReader},
+			{Key: writer, Provider: newWriter},
+			{Key: reader, Provider: newReader},
+		}, cycle)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		newBuffer := &Node{Results: []fxreflect.Key{buffer}}
+		newReader := &Node{
+			Params:  []fxreflect.Param{{Key: buffer}},
+			Results: []fxreflect.Key{reader},
+		}
+		newWriter := &Node{
+			Params:  []fxreflect.Param{{Key: reader}, {Key: buffer}},
+			Results: []fxreflect.Key{writer},
+		}
+
+		g := New()
+		g.AddConstructor(newBuffer)
+		g.AddConstructor(newReader)
+		g.AddConstructor(newWriter)
+
+		_, ok := g.Cycle()
+		assert.False(t, ok)
+	})
+
+	t.Run("LazyIsNotFollowed", func(t *testing.T) {
+		newReader := &Node{
+			Params: []fxreflect.Param{{
+				Key:  fxreflect.Key{Type: reflect.TypeOf(func() (io.Writer, error) { return nil, nil })},
+				Lazy: true,
+			}},
+			Results: []fxreflect.Key{reader},
+		}
+		newWriter := &Node{
+			Params:  []fxreflect.Param{{Key: reader}},
+			Results: []fxreflect.Key{writer},
+		}
+
+		g := New()
+		g.AddConstructor(newReader)
+		g.AddConstructor(newWriter)
+
+		_, ok := g.Cycle()
+		assert.False(t, ok)
+	})
+}
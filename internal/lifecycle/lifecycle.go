@@ -24,6 +24,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
@@ -42,15 +43,48 @@ type Hook struct {
 	callerFrame fxreflect.Frame
 }
 
+// HookError is returned by Start and Stop when a hook panics and the
+// lifecycle recovers from it. Its message is that of the underlying
+// PanicError.
+type HookError struct {
+	Method      string // OnStart or OnStop
+	Func        func(context.Context) error
+	CallerFrame fxreflect.Frame
+	Err         error
+}
+
+func (e *HookError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error returned by the hook.
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned by a hook that panicked if the lifecycle recovers
+// from panics.
+type PanicError struct {
+	Value interface{} // value passed to panic
+	Stack []byte      // stack trace of the goroutine that panicked
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
 // Lifecycle coordinates application lifecycle hooks.
 type Lifecycle struct {
-	logger       fxevent.Logger
-	hooks        []Hook
-	numStarted   int
-	startRecords HookRecords
-	stopRecords  HookRecords
-	runningHook  Hook
-	mu           sync.Mutex
+	logger            fxevent.Logger
+	hooks             []Hook
+	numStarted        int
+	startRecords      HookRecords
+	stopRecords       HookRecords
+	runningHook       Hook
+	recoverFromPanics bool
+	started           bool // between the end of Start and Stop
+	startingAppended  bool // StartAppended is running hooks
+	mu                sync.Mutex
 }
 
 // New constructs a new Lifecycle.
@@ -58,13 +92,30 @@ func New(logger fxevent.Logger) *Lifecycle {
 	return &Lifecycle{logger: logger}
 }
 
+// RecoverFromPanics makes the lifecycle recover from panics in hooks, and
+// treat them as if the hook returned a PanicError.
+func (l *Lifecycle) RecoverFromPanics() {
+	l.recoverFromPanics = true
+}
+
 // Append adds a Hook to the lifecycle.
 func (l *Lifecycle) Append(hook Hook) {
 	// Save the caller's stack frame to report file/line number.
 	if f := fxreflect.CallerStack(2, 0); len(f) > 0 {
 		hook.callerFrame = f[0]
 	}
+	l.mu.Lock()
 	l.hooks = append(l.hooks, hook)
+	l.mu.Unlock()
+}
+
+// AppendFrom adds a Hook to the lifecycle, attributing it to the given
+// caller rather than to the function that called AppendFrom.
+func (l *Lifecycle) AppendFrom(hook Hook, caller fxreflect.Frame) {
+	hook.callerFrame = caller
+	l.mu.Lock()
+	l.hooks = append(l.hooks, hook)
+	l.mu.Unlock()
 }
 
 const (
@@ -73,51 +124,131 @@ const (
 )
 
 // Start runs all OnStart hooks, returning immediately if it encounters an
-// error.
+// error. Hooks appended by the hooks it runs are started in turn.
 func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
 	l.startRecords = make(HookRecords, 0, len(l.hooks))
-	for _, hook := range l.hooks {
-		if hook.OnStart != nil {
-			funcName := fxreflect.FuncName(hook.OnStart)
-			l.logger.LogEvent(&fxevent.LifecycleHookExecuting{
-				CallerName:   hook.callerFrame.Function,
-				FunctionName: funcName,
-				Method:       _hookStart,
-			})
+	l.mu.Unlock()
 
-			l.mu.Lock()
-			l.runningHook = hook
+	for {
+		l.mu.Lock()
+		if l.numStarted == len(l.hooks) {
+			l.started = true
 			l.mu.Unlock()
+			return nil
+		}
+		hook := l.hooks[l.numStarted]
+		l.mu.Unlock()
 
-			begin := time.Now()
-			if err := hook.OnStart(ctx); err != nil {
-				l.logger.LogEvent(&fxevent.LifecycleHookExecuted{
-					CallerName:   hook.callerFrame.Function,
-					FunctionName: funcName,
-					Method:       _hookStart,
-					Runtime:      time.Since(begin),
-					Err:          err,
-				})
-				return err
-			}
-			l.mu.Lock()
-			runtime := time.Since(begin)
-			l.startRecords = append(l.startRecords, HookRecord{
-				CallerFrame: hook.callerFrame,
-				Func:        hook.OnStart,
-				Runtime:     runtime,
-			})
-			l.mu.Unlock()
-			l.logger.LogEvent(&fxevent.LifecycleHookExecuted{
-				CallerName:   hook.callerFrame.Function,
-				FunctionName: funcName,
-				Method:       _hookStart,
-				Runtime:      runtime,
-			})
+		if err := l.startHook(ctx, hook); err != nil {
+			return err
 		}
+
+		l.mu.Lock()
 		l.numStarted++
+		l.mu.Unlock()
 	}
+}
+
+// StartAppended runs the OnStart hooks of the hooks appended since Start
+// returned, so that Stop runs their OnStop hooks. It does nothing if the
+// lifecycle isn't started, or if another call to StartAppended is already
+// running hooks; that call starts the new hooks too.
+//
+// A hook whose OnStart fails is removed from the lifecycle.
+func (l *Lifecycle) StartAppended(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.started || l.startingAppended {
+		l.mu.Unlock()
+		return nil
+	}
+	l.startingAppended = true
+	l.mu.Unlock()
 
+	defer func() {
+		l.mu.Lock()
+		l.startingAppended = false
+		l.mu.Unlock()
+	}()
+
+	for {
+		l.mu.Lock()
+		if !l.started || l.numStarted == len(l.hooks) {
+			l.mu.Unlock()
+			return nil
+		}
+		i := l.numStarted
+		hook := l.hooks[i]
+		l.mu.Unlock()
+
+		err := l.startHook(ctx, hook)
+
+		l.mu.Lock()
+		stopped := !l.started
+		if err != nil || stopped {
+			// Stop won't run the OnStop of a hook that failed to start,
+			// or of one that started after Stop began, which is stopped
+			// below instead.
+			l.hooks = append(l.hooks[:i:i], l.hooks[i+1:]...)
+		} else {
+			l.numStarted++
+		}
+		l.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if stopped {
+			if hook.OnStop != nil {
+				return l.runHook(ctx, hook.OnStop)
+			}
+			return nil
+		}
+	}
+}
+
+// startHook runs the OnStart hook of the given hook, if any.
+func (l *Lifecycle) startHook(ctx context.Context, hook Hook) error {
+	if hook.OnStart == nil {
+		return nil
+	}
+
+	funcName := fxreflect.FuncName(hook.OnStart)
+	l.logger.LogEvent(&fxevent.LifecycleHookExecuting{
+		CallerName:   hook.callerFrame.Function,
+		FunctionName: funcName,
+		Method:       _hookStart,
+	})
+
+	l.mu.Lock()
+	l.runningHook = hook
+	l.mu.Unlock()
+
+	begin := time.Now()
+	if err := l.runHook(ctx, hook.OnStart); err != nil {
+		l.logger.LogEvent(&fxevent.LifecycleHookExecuted{
+			CallerName:   hook.callerFrame.Function,
+			FunctionName: funcName,
+			Method:       _hookStart,
+			Runtime:      time.Since(begin),
+			Err:          err,
+		})
+		return hookError(_hookStart, hook.OnStart, hook.callerFrame, err)
+	}
+	l.mu.Lock()
+	runtime := time.Since(begin)
+	l.startRecords = append(l.startRecords, HookRecord{
+		CallerFrame: hook.callerFrame,
+		Func:        hook.OnStart,
+		Runtime:     runtime,
+	})
+	l.mu.Unlock()
+	l.logger.LogEvent(&fxevent.LifecycleHookExecuted{
+		CallerName:   hook.callerFrame.Function,
+		FunctionName: funcName,
+		Method:       _hookStart,
+		Runtime:      runtime,
+	})
 	return nil
 }
 
@@ -126,12 +257,21 @@ func (l *Lifecycle) Start(ctx context.Context) error {
 func (l *Lifecycle) Stop(ctx context.Context) error {
 	var errs []error
 	l.mu.Lock()
+	l.started = false
 	l.stopRecords = make(HookRecords, 0, l.numStarted)
 	l.mu.Unlock()
 
 	// Run backward from last successful OnStart.
-	for ; l.numStarted > 0; l.numStarted-- {
-		hook := l.hooks[l.numStarted-1]
+	for {
+		l.mu.Lock()
+		if l.numStarted == 0 {
+			l.mu.Unlock()
+			break
+		}
+		l.numStarted--
+		hook := l.hooks[l.numStarted]
+		l.mu.Unlock()
+
 		if hook.OnStop == nil {
 			continue
 		}
@@ -149,7 +289,7 @@ func (l *Lifecycle) Stop(ctx context.Context) error {
 
 		begin := time.Now()
 		var err error
-		if err = hook.OnStop(ctx); err != nil {
+		if err = l.runHook(ctx, hook.OnStop); err != nil {
 			// For best-effort cleanup, keep going after errors.
 			l.logger.LogEvent(&fxevent.LifecycleHookExecuted{
 				CallerName:   hook.callerFrame.Function,
@@ -157,7 +297,7 @@ func (l *Lifecycle) Stop(ctx context.Context) error {
 				Method:       _hookStop,
 				Err:          err,
 			})
-			errs = append(errs, err)
+			errs = append(errs, hookError(_hookStop, hook.OnStop, hook.callerFrame, err))
 		}
 		l.mu.Lock()
 		runtime := time.Since(begin)
@@ -182,6 +322,33 @@ func (l *Lifecycle) Stop(ctx context.Context) error {
 	return multierr.Combine(errs...)
 }
 
+// hookError returns the error reported by a hook. Errors returned by the hook
+// are passed through as-is, so that callers may compare them with the errors
+// they expect. Panics are reported as HookErrors.
+func hookError(method string, fn func(context.Context) error, caller fxreflect.Frame, err error) error {
+	if _, ok := err.(*PanicError); !ok {
+		return err
+	}
+	return &HookError{
+		Method:      method,
+		Func:        fn,
+		CallerFrame: caller,
+		Err:         err,
+	}
+}
+
+// runHook runs the given hook, recovering from panics if necessary.
+func (l *Lifecycle) runHook(ctx context.Context, fn func(context.Context) error) (err error) {
+	if l.recoverFromPanics {
+		defer func() {
+			if v := recover(); v != nil {
+				err = &PanicError{Value: v, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return fn(ctx)
+}
+
 // StartHookRecords returns the info of OnStart hooks that successfully ran till the end,
 // including their caller and runtime. Used to report timeout errors on Start.
 func (l *Lifecycle) StartHookRecords() HookRecords {
@@ -66,6 +66,18 @@ func TestLifecycleStart(t *testing.T) {
 		assert.NoError(t, l.Start(context.Background()))
 		assert.Equal(t, 2, count)
 	})
+	t.Run("AppendFrom", func(t *testing.T) {
+		l := New(testLogger(t))
+		caller := fxreflect.Frame{Function: "foo.NewBar", File: "foo/bar.go", Line: 42}
+		l.AppendFrom(Hook{
+			OnStart: func(context.Context) error { return nil },
+		}, caller)
+
+		require.NoError(t, l.Start(context.Background()))
+		records := l.StartHookRecords()
+		require.Len(t, records, 1)
+		assert.Equal(t, caller, records[0].CallerFrame)
+	})
 	t.Run("ErrHaltsChainAndRollsBack", func(t *testing.T) {
 		l := New(testLogger(t))
 		err := errors.New("a starter error")
@@ -112,6 +124,112 @@ func TestLifecycleStart(t *testing.T) {
 		assert.Equal(t, 2, starterCount, "expected the first and second starter to execute")
 		assert.Equal(t, 1, stopperCount, "expected the first stopper to execute since the second starter failed")
 	})
+
+	t.Run("RecoversFromPanics", func(t *testing.T) {
+		l := New(testLogger(t))
+		l.RecoverFromPanics()
+
+		stopped := false
+		l.Append(Hook{
+			OnStop: func(context.Context) error {
+				stopped = true
+				return nil
+			},
+		})
+		l.Append(Hook{
+			OnStart: func(context.Context) error {
+				panic("great sadness")
+			},
+		})
+
+		err := l.Start(context.Background())
+		require.Error(t, err)
+
+		var hookErr *HookError
+		require.True(t, errors.As(err, &hookErr), "expected a HookError")
+		assert.Equal(t, "OnStart", hookErr.Method)
+
+		var panicErr *PanicError
+		require.True(t, errors.As(err, &panicErr), "expected a PanicError")
+		assert.Equal(t, "great sadness", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "lifecycle_test.go")
+
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.True(t, stopped, "expected the first hook to be stopped")
+	})
+
+}
+
+func TestLifecycleStartAppended(t *testing.T) {
+	t.Run("DoesNothingWhenNotStarted", func(t *testing.T) {
+		l := New(testLogger(t))
+		l.Append(Hook{
+			OnStart: func(context.Context) error {
+				t.Error("this starter shouldnt run before Start")
+				return nil
+			},
+		})
+		assert.NoError(t, l.StartAppended(context.Background()))
+	})
+	t.Run("StartsAndStopsHooksAppendedAfterStart", func(t *testing.T) {
+		l := New(testLogger(t))
+		var calls []string
+		hook := func(name string) Hook {
+			return Hook{
+				OnStart: func(context.Context) error {
+					calls = append(calls, "start "+name)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					calls = append(calls, "stop "+name)
+					return nil
+				},
+			}
+		}
+
+		l.Append(hook("first"))
+		require.NoError(t, l.Start(context.Background()))
+
+		l.Append(hook("second"))
+		require.NoError(t, l.StartAppended(context.Background()))
+		assert.Equal(t, []string{"start first", "start second"}, calls)
+
+		require.NoError(t, l.Stop(context.Background()))
+		assert.Equal(t, []string{"start first", "start second", "stop second", "stop first"}, calls)
+	})
+	t.Run("ErrDropsHook", func(t *testing.T) {
+		l := New(testLogger(t))
+		require.NoError(t, l.Start(context.Background()))
+
+		err := errors.New("a starter error")
+		l.Append(Hook{
+			OnStart: func(context.Context) error { return err },
+			OnStop: func(context.Context) error {
+				t.Error("this stopper shouldnt run, since its starter failed")
+				return nil
+			},
+		})
+		assert.Equal(t, err, l.StartAppended(context.Background()))
+		assert.NoError(t, l.StartAppended(context.Background()))
+		assert.NoError(t, l.Stop(context.Background()))
+	})
+	t.Run("HooksAppendedDuringStart", func(t *testing.T) {
+		l := New(testLogger(t))
+		var calls []string
+		l.Append(Hook{
+			OnStart: func(context.Context) error {
+				l.Append(Hook{
+					OnStart: func(context.Context) error {
+						calls = append(calls, "start inner")
+						return nil
+					},
+				})
+				return nil
+			},
+		})
+		require.NoError(t, l.Start(context.Background()))
+		assert.Equal(t, []string{"start inner"}, calls)
+	})
 }
 
 func TestLifecycleStop(t *testing.T) {
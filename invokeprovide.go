@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// InvokeAndProvide registers functions that are invoked like fx.Invoke,
+// but whose results are added to the application's container, as if they
+// were provided with fx.Provide. This is useful for bootstrap steps with
+// side effects, like running migrations, that produce values other
+// components need.
+//
+//  fx.InvokeAndProvide(func(db *sql.DB) (*Schema, error) {
+//    return migrate(db)
+//  }),
+//  fx.Invoke(func(s *Schema) {
+//    // ...
+//  }),
+//
+// The functions run during New in the order of all invocations, and their
+// results are passed to the functions invoked after them. Like
+// constructors, they run at most once: functions that request their
+// results receive the same values, and functions included more than once
+// are ignored after the first time, as with fx.Provide. They may be wrapped
+// in fx.Annotated to name their results or add them to a value group.
+//
+// If a function fails, New fails with an InvokeError.
+func InvokeAndProvide(funcs ...interface{}) Option {
+	return invokeAndProvideOption{
+		Targets: funcs,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
+}
+
+type invokeAndProvideOption struct {
+	Targets []interface{}
+	Stack   fxreflect.Stack
+}
+
+func (o invokeAndProvideOption) apply(app *App) {
+	for _, target := range o.Targets {
+		app.provides = append(app.provides, provide{
+			Target: target,
+			Stack:  o.Stack,
+			Key:    newProvideKey(target),
+			Source: app.source(o.Stack),
+			Option: "fx.InvokeAndProvide",
+		})
+		app.invokes = append(app.invokes, invoke{
+			Target:   target,
+			Stack:    o.Stack,
+			Provided: true,
+		})
+	}
+}
+
+func (o invokeAndProvideOption) visit(v *optionVisitor) {
+	v.report(o, "fx.InvokeAndProvide", o.Stack, o.Targets...)
+}
+
+func (o invokeAndProvideOption) String() string {
+	items := make([]string, len(o.Targets))
+	for i, f := range o.Targets {
+		items[i] = fxreflect.FuncName(f)
+	}
+	return fmt.Sprintf("fx.InvokeAndProvide(%s)", strings.Join(items, ", "))
+}
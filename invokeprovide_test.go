@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestInvokeAndProvide(t *testing.T) {
+	type A struct{}
+	type B struct{}
+
+	t.Run("ResultsAvailableToLaterInvokes", func(t *testing.T) {
+		var calls []string
+		var got *B
+		app := fxtest.New(t,
+			fx.Provide(func() *A { return &A{} }),
+			fx.Invoke(func() { calls = append(calls, "before") }),
+			fx.InvokeAndProvide(func(*A) *B {
+				calls = append(calls, "bootstrap")
+				return &B{}
+			}),
+			fx.Invoke(func(b *B) {
+				calls = append(calls, "after")
+				got = b
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"before", "bootstrap", "after"}, calls)
+		assert.NotNil(t, got)
+	})
+
+	t.Run("CalledOnce", func(t *testing.T) {
+		var calls int
+		var b1, b2 *B
+		app := fxtest.New(t,
+			fx.InvokeAndProvide(func() *B {
+				calls++
+				return &B{}
+			}),
+			fx.Invoke(func(b *B) { b1 = b }),
+			fx.Invoke(func(b *B) { b2 = b }),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, 1, calls)
+		assert.Same(t, b1, b2)
+	})
+
+	t.Run("Annotated", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			B *B `name:"foo"`
+		}
+
+		var got params
+		app := fxtest.New(t,
+			fx.InvokeAndProvide(fx.Annotated{
+				Name:   "foo",
+				Target: func() *B { return &B{} },
+			}),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.NotNil(t, got.B)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var called bool
+		app := NewForTest(t,
+			fx.InvokeAndProvide(func() (*B, error) {
+				return nil, errors.New("great sadness")
+			}),
+			fx.Invoke(func(*B) { called = true }),
+		)
+
+		err := app.Err()
+		require.Error(t, err)
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError, got %v", err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Contains(t, err.Error(), "TestInvokeAndProvide")
+		assert.False(t, called, "later invokes must not run")
+	})
+
+	t.Run("ProvideError", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(func() *B { return &B{} }),
+			fx.InvokeAndProvide(func() *B { return &B{} }),
+		)
+
+		err := app.Err()
+		require.Error(t, err)
+		var provideErr *fx.ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError, got %v", err)
+		assert.Contains(t, err.Error(), "fx.InvokeAndProvide(go.uber.org/fx_test.TestInvokeAndProvide")
+		assert.Contains(t, err.Error(), "already provided")
+	})
+
+	t.Run("DuplicatesAreIgnored", func(t *testing.T) {
+		var calls int
+		newB := func() *B {
+			calls++
+			return &B{}
+		}
+		module := fx.InvokeAndProvide(newB)
+
+		var got *B
+		app := fxtest.New(t,
+			module,
+			fx.Options(module),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, 1, calls)
+		assert.NotNil(t, got)
+	})
+}
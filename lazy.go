@@ -0,0 +1,340 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/dig"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+)
+
+// lazyField is a function that builds a value on demand, func() (T, error),
+// requested by a parameter or a field of a parameter struct.
+type lazyField struct {
+	Index    int           // index of the field in the parameter struct
+	Key      fxreflect.Key // key of the value built by the function
+	Optional bool          // whether the value may be missing
+}
+
+// lazyParam is a parameter that builds a value on demand, or a parameter
+// struct with one or more fields that do.
+type lazyParam struct {
+	// Original parameter type expected by the function.
+	Type reflect.Type
+
+	// Parameter struct type presented to dig. This holds all fields of the
+	// original struct, except that lazy fields are optional. If the
+	// original parameter is not a parameter struct, this holds it as its
+	// only field.
+	DigType reflect.Type
+
+	// Index of each field of Type in DigType, or -1 for unexported fields.
+	// Nil if Type is not a parameter struct.
+	DigFields []int
+
+	Lazy []lazyField
+}
+
+// newLazyParam inspects the given parameter type and returns a lazyParam if
+// it, or one of its fields, is of the form func() (T, error). It returns nil
+// otherwise.
+func newLazyParam(t reflect.Type) *lazyParam {
+	if _, ok := fxreflect.LazyType(t); ok {
+		return &lazyParam{
+			Type: t,
+			DigType: reflect.StructOf([]reflect.StructField{
+				{Name: "In", Type: reflect.TypeOf(dig.In{}), Anonymous: true},
+				{Name: "Func", Type: t, Tag: `optional:"true"`},
+			}),
+			Lazy: []lazyField{{Key: lazyKey(t, "")}},
+		}
+	}
+
+	if t.Kind() != reflect.Struct || !dig.IsIn(t) {
+		return nil
+	}
+
+	p := lazyParam{
+		Type:      t,
+		DigFields: make([]int, t.NumField()),
+	}
+	fields := make([]reflect.StructField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		p.DigFields[i] = -1
+
+		// Unexported fields are never filled by dig so there's no need to
+		// carry them over.
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if _, ok := fxreflect.LazyType(f.Type); ok {
+			optional, _ := strconv.ParseBool(f.Tag.Get("optional"))
+			p.Lazy = append(p.Lazy, lazyField{
+				Index:    i,
+				Key:      lazyKey(f.Type, f.Tag.Get("name")),
+				Optional: optional,
+			})
+
+			// dig uses the first occurrence of a tag.
+			f.Tag = reflect.StructTag(`optional:"true" ` + string(f.Tag))
+		}
+
+		p.DigFields[i] = len(fields)
+		fields = append(fields, f)
+	}
+
+	if len(p.Lazy) == 0 {
+		return nil
+	}
+
+	p.DigType = reflect.StructOf(fields)
+	return &p
+}
+
+// lazyKey returns the key of the value built by a function of type t,
+// func() (T, error), with the given name.
+func lazyKey(t reflect.Type, name string) fxreflect.Key {
+	elem, _ := fxreflect.LazyType(t)
+	return fxreflect.Key{Type: elem, Name: name}
+}
+
+// lazyCall is a call of a function with lazy parameters. Lazy functions
+// called by the function while it runs are called from within a request
+// made to the container.
+type lazyCall struct {
+	running int32 // accessed atomically
+}
+
+// Build constructs the original parameter from a value of DigType, filling
+// in lazy functions that weren't provided to the container.
+func (p *lazyParam) Build(app *App, v reflect.Value, call *lazyCall) (reflect.Value, error) {
+	if p.DigFields == nil {
+		return app.lazyValue(v.Field(1), p.Lazy[0], call)
+	}
+
+	result := reflect.New(p.Type).Elem()
+	for i, j := range p.DigFields {
+		if j >= 0 {
+			result.Field(i).Set(v.Field(j))
+		}
+	}
+
+	for _, f := range p.Lazy {
+		field := result.Field(f.Index)
+		fn, err := app.lazyValue(field, f, call)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		field.Set(fn)
+	}
+	return result, nil
+}
+
+// lazyValue returns fn if it was provided to the container. Otherwise, it
+// returns a function that builds the value of the given field on demand,
+// after verifying that the value can be built.
+func (app *App) lazyValue(fn reflect.Value, f lazyField, call *lazyCall) (reflect.Value, error) {
+	if !fn.IsNil() {
+		return fn, nil
+	}
+
+	path, missing := app.graph.MissingPath(&graph.Node{
+		Params: []fxreflect.Param{{Key: f.Key}},
+	})
+	switch {
+	case missing && f.Optional:
+		return fn, nil
+	case missing:
+		return reflect.Value{}, errMissingLazy(f.Key, path)
+	}
+
+	return app.newLazy(fn.Type(), f.Key, call), nil
+}
+
+// checkLazy verifies that the values constructors request on demand can be
+// built, so that a missing value fails New rather than the first call of
+// the function that builds it.
+func (app *App) checkLazy() {
+	if app.err != nil {
+		return
+	}
+
+	for _, n := range app.graph.Constructors {
+		if n.Builtin {
+			continue
+		}
+		for _, p := range n.Params {
+			if !p.Lazy || p.Optional {
+				continue
+			}
+			k, lazy := app.graph.Dependency(p)
+			if !lazy {
+				continue
+			}
+			path, missing := app.graph.MissingPath(&graph.Node{
+				Params: []fxreflect.Param{{Key: k}},
+			})
+			if missing {
+				explanation := explainMissing(app.graph, &graph.Node{
+					Func:   n.Func,
+					Stack:  n.Stack,
+					Params: []fxreflect.Param{p},
+				})
+				app.err = &ProvideError{
+					Constructor: n.Func,
+					Stack:       n.Stack,
+					Err:         fmt.Errorf("%w%v", errMissingLazy(k, path), explanation),
+				}
+				return
+			}
+		}
+	}
+}
+
+// errMissingLazy reports that the value with the given key can't be built
+// on demand because the value at the end of the given path is missing.
+func errMissingLazy(k fxreflect.Key, path []graph.Step) error {
+	return fmt.Errorf("cannot build %v on demand: missing type: %v", k, path[len(path)-1].Key)
+}
+
+// newLazy builds a function of type t, func() (T, error), that requests the
+// value with the given key from the container the first time it's called.
+// It returns the same value on every call after that. The function is
+// passed to the given call.
+func (app *App) newLazy(t reflect.Type, k fxreflect.Key, call *lazyCall) reflect.Value {
+	var (
+		mu    sync.Mutex
+		value reflect.Value
+	)
+	return reflect.MakeFunc(t, func([]reflect.Value) []reflect.Value {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !value.IsValid() {
+			var (
+				v   reflect.Value
+				err error
+			)
+			if atomic.LoadInt32(&call.running) == 1 {
+				// The container is already running the function this
+				// was passed to. Requesting the value through App.value
+				// would wait for that request to end.
+				v, err = app.resolve(k)
+			} else {
+				v, err = app.value(k)
+			}
+			if err != nil {
+				return []reflect.Value{reflect.Zero(k.Type), reflect.ValueOf(&err).Elem()}
+			}
+			value = v
+		}
+		return []reflect.Value{value, reflect.Zero(_typeOfError)}
+	})
+}
+
+// lazyFunc returns a function with the same behavior as fn, except that
+// parameters of the form func() (T, error), and fields of its parameter
+// structs of that form, are built by Fx if they weren't provided to the
+// container. The functions built by Fx request T from the container the
+// first time they're called.
+//
+// If fn does not return an error, the returned function will, so that it can
+// report values that cannot be built. fn is returned as-is if none of its
+// parameters are lazy.
+func (app *App) lazyFunc(fn interface{}) interface{} {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fn
+	}
+	ft := fv.Type()
+
+	var (
+		params  = make([]*lazyParam, ft.NumIn())
+		in      = make([]reflect.Type, ft.NumIn())
+		hasLazy bool
+	)
+	for i := range in {
+		in[i] = ft.In(i)
+		if ft.IsVariadic() && i == len(in)-1 {
+			continue
+		}
+
+		if p := newLazyParam(ft.In(i)); p != nil {
+			params[i] = p
+			in[i] = p.DigType
+			hasLazy = true
+		}
+	}
+	if !hasLazy {
+		return fn
+	}
+
+	out := make([]reflect.Type, ft.NumOut())
+	for i := range out {
+		out[i] = ft.Out(i)
+	}
+	returnsErr := len(out) > 0 && out[len(out)-1] == _typeOfError
+	if !returnsErr {
+		out = append(out, _typeOfError)
+	}
+
+	newFt := reflect.FuncOf(in, out, ft.IsVariadic())
+	return reflect.MakeFunc(newFt, func(args []reflect.Value) []reflect.Value {
+		call := &lazyCall{running: 1}
+		defer atomic.StoreInt32(&call.running, 0)
+
+		for i, p := range params {
+			if p == nil {
+				continue
+			}
+
+			v, err := p.Build(app, args[i], call)
+			if err != nil {
+				results := make([]reflect.Value, len(out))
+				for i, t := range out {
+					results[i] = reflect.Zero(t)
+				}
+				results[len(results)-1] = reflect.ValueOf(&err).Elem()
+				return results
+			}
+			args[i] = v
+		}
+
+		var results []reflect.Value
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+		if !returnsErr {
+			results = append(results, reflect.Zero(_typeOfError))
+		}
+		return results
+	}).Interface()
+}
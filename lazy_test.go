@@ -0,0 +1,378 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestLazy(t *testing.T) {
+	type Admin struct{ Name string }
+	type Logger struct{}
+
+	t.Run("BuildsOnFirstCall", func(t *testing.T) {
+		calls := 0
+		var getAdmin func() (*Admin, error)
+		app := fxtest.New(t,
+			fx.Provide(func() *Admin {
+				calls++
+				return &Admin{Name: "admin"}
+			}),
+			fx.Invoke(func(get func() (*Admin, error)) {
+				getAdmin = get
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Zero(t, calls, "constructor must not run until requested")
+
+		a1, err := getAdmin()
+		require.NoError(t, err)
+		assert.Equal(t, "admin", a1.Name)
+
+		a2, err := getAdmin()
+		require.NoError(t, err)
+		assert.Same(t, a1, a2)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("HooksAppendedAfterStart", func(t *testing.T) {
+		var (
+			calls    []string
+			getAdmin func() (*Admin, error)
+		)
+		app := fxtest.New(t,
+			fx.Provide(func(lc fx.Lifecycle) *Admin {
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error {
+						calls = append(calls, "start")
+						return nil
+					},
+					OnStop: func(context.Context) error {
+						calls = append(calls, "stop")
+						return nil
+					},
+				})
+				return &Admin{}
+			}),
+			fx.Populate(&getAdmin),
+		)
+		app.RequireStart()
+		assert.Empty(t, calls)
+
+		_, err := getAdmin()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"start"}, calls)
+
+		app.RequireStop()
+		assert.Equal(t, []string{"start", "stop"}, calls)
+	})
+
+	t.Run("HookFailsAfterStart", func(t *testing.T) {
+		var getAdmin func() (*Admin, error)
+		app := fxtest.New(t,
+			fx.Provide(func(lc fx.Lifecycle) *Admin {
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error {
+						return errors.New("great sadness")
+					},
+					OnStop: func(context.Context) error {
+						t.Error("must not be called")
+						return nil
+					},
+				})
+				return &Admin{}
+			}),
+			fx.Populate(&getAdmin),
+		)
+		defer app.RequireStart().RequireStop()
+
+		_, err := getAdmin()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+
+	t.Run("ParameterStruct", func(t *testing.T) {
+		var got *Admin
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Name:   "primary",
+					Target: func() *Admin { return &Admin{Name: "primary"} },
+				},
+				func() *Logger { return &Logger{} },
+			),
+			fx.Invoke(func(p struct {
+				fx.In
+
+				Logger *Logger
+				Admin  func() (*Admin, error) `name:"primary"`
+			}) error {
+				var err error
+				got, err = p.Admin()
+				return err
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		require.NotNil(t, got)
+		assert.Equal(t, "primary", got.Name)
+	})
+
+	t.Run("Constructor", func(t *testing.T) {
+		type Server struct{ admin func() (*Admin, error) }
+
+		var s *Server
+		app := fxtest.New(t,
+			fx.Provide(
+				func() *Admin { return &Admin{Name: "admin"} },
+				func(admin func() (*Admin, error)) *Server { return &Server{admin: admin} },
+			),
+			fx.Populate(&s),
+		)
+		defer app.RequireStart().RequireStop()
+
+		a, err := s.admin()
+		require.NoError(t, err)
+		assert.Equal(t, "admin", a.Name)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Invoke(func(func() (*Admin, error)) {
+				t.Fatal("must not be called")
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot build *fx_test.Admin on demand: missing type: *fx_test.Admin")
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(func(*Logger) *Admin { return &Admin{} }),
+			fx.Invoke(func(func() (*Admin, error)) {
+				t.Fatal("must not be called")
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot build *fx_test.Admin on demand: missing type: *fx_test.Logger")
+	})
+
+	t.Run("Optional", func(t *testing.T) {
+		called := false
+		app := fxtest.New(t,
+			fx.Invoke(func(p struct {
+				fx.In
+
+				Admin func() (*Admin, error) `optional:"true"`
+			}) {
+				called = true
+				assert.Nil(t, p.Admin)
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.True(t, called)
+	})
+
+	t.Run("ConstructorFails", func(t *testing.T) {
+		var getAdmin func() (*Admin, error)
+		app := fxtest.New(t,
+			fx.Provide(func() (*Admin, error) { return nil, errors.New("great sadness") }),
+			fx.Populate(&getAdmin),
+		)
+		defer app.RequireStart().RequireStop()
+
+		_, err := getAdmin()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Contains(t, err.Error(), `received non-nil error from function go.uber.org/fx_test.TestLazy.func`)
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+	})
+
+	t.Run("ProvidedFunctionsTakePrecedence", func(t *testing.T) {
+		admin := &Admin{Name: "provided"}
+		var got *Admin
+		app := fxtest.New(t,
+			fx.Provide(func() *Admin { return &Admin{Name: "constructed"} }),
+			fx.Provide(func() func() (*Admin, error) {
+				return func() (*Admin, error) { return admin, nil }
+			}),
+			fx.Invoke(func(get func() (*Admin, error)) error {
+				var err error
+				got, err = get()
+				return err
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Same(t, admin, got)
+	})
+
+	t.Run("NotUnused", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Strict(),
+			fx.Provide(func() *Admin { return &Admin{} }),
+			fx.Invoke(func(func() (*Admin, error)) {}),
+		)
+		defer app.RequireStart().RequireStop()
+	})
+
+	t.Run("ProvidedFunctionNotUnused", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Strict(),
+			fx.Provide(func() func() (int, error) {
+				return func() (int, error) { return 42, nil }
+			}),
+			fx.Invoke(func(func() (int, error)) {}),
+		)
+		defer app.RequireStart().RequireStop()
+	})
+
+	t.Run("MissingInConstructor", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(func(func() (*Logger, error)) *Admin { return &Admin{} }),
+			fx.Invoke(func(func() (*Admin, error)) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		var provideErr *fx.ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+		assert.Contains(t, err.Error(), "fx.Provide(go.uber.org/fx_test.TestLazy.func")
+		assert.Contains(t, err.Error(), "lazy_test.go")
+		assert.Contains(t, err.Error(), "cannot build *fx_test.Logger on demand: missing type: *fx_test.Logger")
+		assert.Contains(t, err.Error(), "-> *fx_test.Logger (missing)")
+	})
+
+	t.Run("MissingInUnusedConstructor", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.Provide(func(func() (*Logger, error)) *Admin {
+				t.Fatal("must not be called")
+				return nil
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		var provideErr *fx.ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+		assert.Contains(t, err.Error(), "cannot build *fx_test.Logger on demand: missing type: *fx_test.Logger")
+	})
+
+	t.Run("OptionalInConstructor", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Provide(func(p struct {
+				fx.In
+
+				Logger func() (*Logger, error) `optional:"true"`
+			}) *Admin {
+				return &Admin{}
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		var g fx.Graph
+		app := fxtest.New(t,
+			fx.Provide(func() *Admin { return &Admin{} }),
+			fx.Invoke(func(func() (*Admin, error)) {}),
+			fx.Populate(&g),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var found bool
+		for _, e := range g.Edges {
+			if e.Type == "*fx_test.Admin" {
+				found = true
+				assert.True(t, e.Lazy, "expected a lazy edge")
+			}
+		}
+		assert.True(t, found, "expected an edge for *fx_test.Admin in %v", g.Edges)
+	})
+
+	t.Run("ConcurrentCalls", func(t *testing.T) {
+		var p struct {
+			fx.In
+
+			Admin  func() (*Admin, error)
+			Logger func() (*Logger, error)
+		}
+		app := fxtest.New(t,
+			fx.Provide(
+				func() *Admin { return &Admin{} },
+				func() *Logger { return &Logger{} },
+			),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				_, err := p.Admin()
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := p.Logger()
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				child := app.NewChild(fx.Invoke(func(*Admin, *Logger) {}))
+				assert.NoError(t, child.Err())
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("CalledByConstructorOfInheritedValue", func(t *testing.T) {
+		type Service struct{ Admin *Admin }
+
+		app := fxtest.New(t,
+			fx.Provide(
+				func() *Admin { return &Admin{Name: "admin"} },
+				func(get func() (*Admin, error)) (*Service, error) {
+					a, err := get()
+					return &Service{Admin: a}, err
+				},
+			),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var s *Service
+		child := app.NewChild(fx.Populate(&s))
+		require.NoError(t, child.Err())
+		assert.Equal(t, "admin", s.Admin.Name)
+	})
+}
@@ -22,7 +22,11 @@ package fx
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sync"
 
+	"go.uber.org/fx/internal/fxreflect"
 	"go.uber.org/fx/internal/lifecycle"
 )
 
@@ -42,15 +46,155 @@ type Hook struct {
 	OnStop  func(context.Context) error
 }
 
-type lifecycleWrapper struct{ *lifecycle.Lifecycle }
+// StartHook returns a Hook that runs fn when the application starts. fn
+// may be any of the following:
+//
+//  func()
+//  func() error
+//  func(context.Context)
+//  func(context.Context) error
+//
+// This lets methods be used as hooks directly, regardless of whether they
+// take a context or return an error.
+//
+//  lc.Append(fx.StartHook(server.Start))
+//
+// If fn is of any other type, New fails with an error that reports where
+// StartHook was called from. If the hook is appended after New, it fails
+// when it runs instead.
+func StartHook(fn interface{}) Hook {
+	return Hook{OnStart: hookFunc("fx.StartHook", fn, fxreflect.CallerStack(1, 0))}
+}
+
+// StopHook returns a Hook that runs fn when the application stops. fn may
+// be any of the types accepted by StartHook.
+//
+//  lc.Append(fx.StopHook(conn.Close))
+func StopHook(fn interface{}) Hook {
+	return Hook{OnStop: hookFunc("fx.StopHook", fn, fxreflect.CallerStack(1, 0))}
+}
+
+// StartStopHook returns a Hook that runs start when the application starts
+// and stop when it stops. Both may be any of the types accepted by
+// StartHook.
+//
+//  lc.Append(fx.StartStopHook(server.Start, server.Stop))
+func StartStopHook(start, stop interface{}) Hook {
+	stack := fxreflect.CallerStack(1, 0)
+	return Hook{
+		OnStart: hookFunc("fx.StartStopHook", start, stack),
+		OnStop:  hookFunc("fx.StartStopHook", stop, stack),
+	}
+}
+
+// hookFunc adapts fn, which was passed to the named function from the
+// given stack, into a hook function. If fn is of an unsupported type, the
+// returned function is the run method of a badHookFunc.
+func hookFunc(name string, fn interface{}, stack fxreflect.Stack) func(context.Context) error {
+	switch fn := fn.(type) {
+	case func(context.Context) error:
+		if fn != nil {
+			return fn
+		}
+	case func(context.Context):
+		if fn != nil {
+			return func(ctx context.Context) error {
+				fn(ctx)
+				return nil
+			}
+		}
+	case func() error:
+		if fn != nil {
+			return func(context.Context) error {
+				return fn()
+			}
+		}
+	case func():
+		if fn != nil {
+			return func(context.Context) error {
+				fn()
+				return nil
+			}
+		}
+	}
+
+	err := fmt.Errorf("%v received %v (type %T) from:\n%+v"+
+		"Failed: hook functions must be func(), func() error, "+
+		"func(context.Context), or func(context.Context) error",
+		name, fxreflect.FuncName(fn), fn, stack)
+	return badHookFunc{err: err}.run
+}
+
+// badHookFunc is the hook function used in place of a function of an
+// unsupported type. Its run method fails with err, but Append recognizes
+// it and reports err before the application starts.
+type badHookFunc struct{ err error }
+
+func (f badHookFunc) run(context.Context) error { return f.err }
+
+// _badHookRun is the code shared by all badHookFunc.run method values.
+var _badHookRun = reflect.ValueOf(badHookFunc{}.run).Pointer()
+
+// badHookErr returns the error reported by fn if it's the run method of a
+// badHookFunc, and nil otherwise. The function is pure, so calling it has
+// no effect beyond returning the error.
+func badHookErr(fn func(context.Context) error) error {
+	if fn == nil || reflect.ValueOf(fn).Pointer() != _badHookRun {
+		return nil
+	}
+	return fn(context.Background())
+}
+
+type lifecycleWrapper struct {
+	*lifecycle.Lifecycle
+
+	mu      sync.Mutex
+	hookErr error // first error of the hooks appended
+}
 
 func (l *lifecycleWrapper) Append(h Hook) {
+	err := badHookErr(h.OnStart)
+	if err == nil {
+		err = badHookErr(h.OnStop)
+	}
+	if err != nil {
+		l.mu.Lock()
+		if l.hookErr == nil {
+			l.hookErr = err
+		}
+		l.mu.Unlock()
+	}
+
 	l.Lifecycle.Append(lifecycle.Hook{
 		OnStart: h.OnStart,
 		OnStop:  h.OnStop,
 	})
 }
 
+// Start runs all OnStart hooks, reporting panics as LifecycleErrors.
+func (l *lifecycleWrapper) Start(ctx context.Context) error {
+	return newLifecycleError(l.Lifecycle.Start(ctx))
+}
+
+// startAppended runs the OnStart hooks appended since the lifecycle
+// started, reporting panics as LifecycleErrors.
+func (l *lifecycleWrapper) startAppended(ctx context.Context) error {
+	return newLifecycleError(l.Lifecycle.StartAppended(ctx))
+}
+
+// Stop runs OnStop hooks, reporting panics as LifecycleErrors.
+func (l *lifecycleWrapper) Stop(ctx context.Context) error {
+	return newLifecycleError(l.Lifecycle.Stop(ctx))
+}
+
+// err returns the error of the first hook appended with a function of an
+// unsupported type, if any.
+func (l *lifecycleWrapper) err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hookErr
+}
+
 func (l *lifecycleWrapper) startHookRecords() lifecycle.HookRecords {
 	return l.StartHookRecords()
 }
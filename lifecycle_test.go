@@ -0,0 +1,209 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type hookServer struct{ calls []string }
+
+func (s *hookServer) Start() {
+	s.calls = append(s.calls, "Start")
+}
+
+func (s *hookServer) StartErr() error {
+	s.calls = append(s.calls, "StartErr")
+	return nil
+}
+
+func (s *hookServer) StartCtx(context.Context) {
+	s.calls = append(s.calls, "StartCtx")
+}
+
+func (s *hookServer) StartCtxErr(context.Context) error {
+	s.calls = append(s.calls, "StartCtxErr")
+	return nil
+}
+
+func (s *hookServer) Stop() error {
+	s.calls = append(s.calls, "Stop")
+	return nil
+}
+
+func TestHookHelpers(t *testing.T) {
+	t.Run("Signatures", func(t *testing.T) {
+		var s hookServer
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.StartHook(s.Start))
+				lc.Append(fx.StartHook(s.StartErr))
+				lc.Append(fx.StartHook(s.StartCtx))
+				lc.Append(fx.StartHook(s.StartCtxErr))
+				lc.Append(fx.StopHook(s.Stop))
+			}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"Start", "StartErr", "StartCtx", "StartCtxErr", "Stop"}, s.calls)
+	})
+
+	t.Run("StartStopHook", func(t *testing.T) {
+		var s hookServer
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.StartStopHook(s.StartCtx, s.Stop))
+			}),
+		)
+		app.RequireStart()
+		assert.Equal(t, []string{"StartCtx"}, s.calls)
+		app.RequireStop()
+		assert.Equal(t, []string{"StartCtx", "Stop"}, s.calls)
+	})
+
+	t.Run("UnkeyedHook", func(t *testing.T) {
+		var s hookServer
+		start := fx.StartHook(s.Start)
+		stop := fx.StopHook(s.Stop)
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{start.OnStart, stop.OnStop})
+			}),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{"Start", "Stop"}, s.calls)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.StartHook(func() error { return errors.New("great sadness") }))
+			}),
+		)
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+
+	t.Run("UnsupportedSignature", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			give func(fx.Lifecycle)
+			want string
+		}{
+			{
+				desc: "StartHook",
+				give: func(lc fx.Lifecycle) { lc.Append(fx.StartHook(func(int) {})) },
+				want: "fx.StartHook received go.uber.org/fx_test.TestHookHelpers",
+			},
+			{
+				desc: "StopHook",
+				give: func(lc fx.Lifecycle) { lc.Append(fx.StopHook(42)) },
+				want: "fx.StopHook received 42 (type int)",
+			},
+			{
+				desc: "StartStopHook",
+				give: func(lc fx.Lifecycle) { lc.Append(fx.StartStopHook(func() {}, func() int { return 0 })) },
+				want: "fx.StartStopHook received go.uber.org/fx_test.TestHookHelpers",
+			},
+			{
+				desc: "nil",
+				give: func(lc fx.Lifecycle) { lc.Append(fx.StartHook(nil)) },
+				want: "fx.StartHook received <nil> (type <nil>)",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := NewForTest(t, fx.Invoke(tt.give))
+				err := app.Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+				assert.Contains(t, err.Error(), "fx/lifecycle_test.go")
+				assert.Contains(t, err.Error(), "hook functions must be func(), func() error, "+
+					"func(context.Context), or func(context.Context) error")
+			})
+		}
+	})
+
+	t.Run("UnsupportedSignatureAfterNew", func(t *testing.T) {
+		var lc fx.Lifecycle
+		app := fxtest.New(t, fx.Populate(&lc))
+		lc.Append(fx.StartHook("foo"))
+
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `fx.StartHook received foo (type string)`)
+	})
+
+	t.Run("UnsupportedSignatureCopied", func(t *testing.T) {
+		bad := fx.StartHook(42)
+		app := NewForTest(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{OnStop: bad.OnStart})
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `fx.StartHook received 42 (type int)`)
+	})
+
+	t.Run("FailingHookIsSupported", func(t *testing.T) {
+		sadness := errors.New("great sadness")
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error { return sadness },
+				})
+			}),
+		)
+		require.NoError(t, app.Err())
+
+		err := app.Start(context.Background())
+		assert.Equal(t, sadness, err)
+	})
+	t.Run("FailingMethodIsSupported", func(t *testing.T) {
+		sadness := errors.New("great sadness")
+		app := fxtest.New(t,
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{OnStart: failingHook{err: sadness}.run})
+			}),
+		)
+		require.NoError(t, app.Err())
+
+		err := app.Start(context.Background())
+		assert.Equal(t, sadness, err)
+	})
+}
+
+// failingHook has a method shaped like the hook functions Fx builds for
+// unsupported types.
+type failingHook struct{ err error }
+
+func (h failingHook) run(context.Context) error { return h.err }
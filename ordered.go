@@ -0,0 +1,658 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/dig"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+)
+
+// groupValue is a value given an order or a key in its value group. dig
+// does not keep values of a group in any particular order, nor does it know
+// about keys, so these values are provided to a hidden group named after
+// the original one, see hiddenGroup, and sorted or collected into maps by
+// Fx when they're consumed.
+type groupValue struct {
+	Type  reflect.Type // type of the value in its group
+	Order int
+	Key   string
+
+	// Position of the constructor among the ones that produce grouped
+	// values, and of the value among the ones it produces. Values with the
+	// same order keep the order they were provided in.
+	Seq   int
+	Index int
+
+	Value reflect.Value
+}
+
+var _typeOfGroupValues = reflect.TypeOf([]groupValue(nil))
+
+// hiddenGroup returns the name of the hidden group that holds the values
+// of the given group that were given an order or a key.
+func hiddenGroup(group string) string {
+	return "fx.grouped:" + group
+}
+
+// splitGroup splits a group annotation into the name of the group and
+// whether it has the flatten option.
+func splitGroup(group string) (name string, flatten bool) {
+	opts := strings.Split(group, ",")
+	for _, opt := range opts[1:] {
+		if opt == "flatten" {
+			flatten = true
+		}
+	}
+	return opts[0], flatten
+}
+
+// appendGroupValues appends v, or its elements if flatten is set, to the
+// given values, with the order and key of gv.
+func appendGroupValues(values []groupValue, v reflect.Value, flatten bool, gv groupValue) []groupValue {
+	if !flatten {
+		gv.Type = v.Type()
+		gv.Value = v
+		gv.Index = len(values)
+		return append(values, gv)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		values = appendGroupValues(values, v.Index(i), false, gv)
+	}
+	return values
+}
+
+// sortGroup returns the values of a group of type t, []T, given the values
+// dig provided to the group and the values of its hidden group. Values are
+// sorted by their order. Values without an order have order 0.
+func sortGroup(t reflect.Type, values reflect.Value, hidden []groupValue) reflect.Value {
+	var before, after []groupValue
+	for _, gv := range hidden {
+		switch {
+		case gv.Type != t.Elem():
+			// Values of a group share a hidden group regardless of their
+			// type.
+		case gv.Order < 0:
+			before = append(before, gv)
+		default:
+			after = append(after, gv)
+		}
+	}
+	if len(before) == 0 && len(after) == 0 {
+		return values
+	}
+
+	for _, gvs := range [][]groupValue{before, after} {
+		sort.Slice(gvs, func(i, j int) bool {
+			a, b := gvs[i], gvs[j]
+			switch {
+			case a.Order != b.Order:
+				return a.Order < b.Order
+			case a.Seq != b.Seq:
+				return a.Seq < b.Seq
+			default:
+				return a.Index < b.Index
+			}
+		})
+	}
+
+	result := reflect.MakeSlice(t, 0, len(before)+values.Len()+len(after))
+	for _, gv := range before {
+		result = reflect.Append(result, gv.Value)
+	}
+	result = reflect.AppendSlice(result, values)
+	for _, gv := range after {
+		result = reflect.Append(result, gv.Value)
+	}
+	return result
+}
+
+// mapGroup returns the values of a group of type t, map[string]T, given the
+// values of its hidden group. Only values given a key are included. Keys
+// are unique within a group; see App.checkGroupKeys.
+func mapGroup(t reflect.Type, hidden []groupValue) reflect.Value {
+	result := reflect.MakeMap(t)
+	for _, gv := range hidden {
+		if gv.Type == t.Elem() && len(gv.Key) > 0 {
+			result.SetMapIndex(reflect.ValueOf(gv.Key).Convert(t.Key()), gv.Value)
+		}
+	}
+	return result
+}
+
+// groupParam is a parameter struct with one or more value group fields.
+type groupParam struct {
+	// Original parameter type expected by the function.
+	Type reflect.Type
+
+	// Parameter struct type presented to dig. This holds all exported
+	// fields of the original struct, except for value groups consumed as
+	// maps, followed by a field for the hidden group of each value group
+	// field.
+	DigType reflect.Type
+
+	// Index of each field of Type in DigType, or -1 for fields that dig
+	// doesn't fill.
+	DigFields []int
+
+	// Index of each value group field in Type, and of the field for its
+	// hidden group in DigType.
+	Groups [][2]int
+}
+
+// newGroupParam returns a groupParam if t is a parameter struct with one or
+// more value group fields. It returns nil otherwise.
+func newGroupParam(t reflect.Type) *groupParam {
+	if t.Kind() != reflect.Struct || !dig.IsIn(t) {
+		return nil
+	}
+
+	p := groupParam{
+		Type:      t,
+		DigFields: make([]int, t.NumField()),
+	}
+	fields := make([]reflect.StructField, 0, t.NumField())
+	var hidden []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		p.DigFields[i] = -1
+		if f.PkgPath != "" {
+			continue
+		}
+
+		g := f.Tag.Get("group")
+		isMap := len(g) > 0 && isGroupMap(f.Type)
+		if !isMap {
+			p.DigFields[i] = len(fields)
+			fields = append(fields, f)
+		}
+
+		if len(g) > 0 && (isMap || f.Type.Kind() == reflect.Slice) {
+			name, _ := splitGroup(g)
+			p.Groups = append(p.Groups, [2]int{i, len(hidden)})
+			hidden = append(hidden, reflect.StructField{
+				Name: fmt.Sprintf("FxGrouped%d", len(hidden)),
+				Type: _typeOfGroupValues,
+				Tag:  reflect.StructTag(fmt.Sprintf(`group:"%v"`, hiddenGroup(name))),
+			})
+		}
+	}
+
+	if len(p.Groups) == 0 {
+		return nil
+	}
+
+	for i := range p.Groups {
+		p.Groups[i][1] += len(fields)
+	}
+	p.DigType = reflect.StructOf(append(fields, hidden...))
+	return &p
+}
+
+// isGroupMap reports whether t is a map that may hold the values of a
+// group, map[string]T.
+func isGroupMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
+
+// Build constructs the original parameter from a value of DigType, sorting
+// the values of each group, or collecting them into maps.
+func (p *groupParam) Build(v reflect.Value) reflect.Value {
+	result := reflect.New(p.Type).Elem()
+	for i, j := range p.DigFields {
+		if j >= 0 {
+			result.Field(i).Set(v.Field(j))
+		}
+	}
+
+	for _, g := range p.Groups {
+		field := result.Field(g[0])
+		hidden := v.Field(g[1]).Interface().([]groupValue)
+		if field.Kind() == reflect.Map {
+			field.Set(mapGroup(field.Type(), hidden))
+		} else {
+			field.Set(sortGroup(field.Type(), field, hidden))
+		}
+	}
+	return result
+}
+
+// groupFunc returns a function with the same behavior as fn, except that
+// the value group fields of its parameter structs hold ordered values in
+// order, and value groups may be consumed as maps of the values given a
+// key. fn is returned as-is if none of its parameters have value group
+// fields.
+func groupFunc(fn interface{}) interface{} {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fn
+	}
+	ft := fv.Type()
+
+	var (
+		params    = make([]*groupParam, ft.NumIn())
+		in        = make([]reflect.Type, ft.NumIn())
+		hasGroups bool
+	)
+	for i := range in {
+		in[i] = ft.In(i)
+		if ft.IsVariadic() && i == len(in)-1 {
+			continue
+		}
+
+		if p := newGroupParam(ft.In(i)); p != nil {
+			params[i] = p
+			in[i] = p.DigType
+			hasGroups = true
+		}
+	}
+	if !hasGroups {
+		return fn
+	}
+
+	out := make([]reflect.Type, ft.NumOut())
+	for i := range out {
+		out[i] = ft.Out(i)
+	}
+
+	newFt := reflect.FuncOf(in, out, ft.IsVariadic())
+	return reflect.MakeFunc(newFt, func(args []reflect.Value) []reflect.Value {
+		for i, p := range params {
+			if p != nil {
+				args[i] = p.Build(args[i])
+			}
+		}
+
+		if ft.IsVariadic() {
+			return fv.CallSlice(args)
+		}
+		return fv.Call(args)
+	}).Interface()
+}
+
+// invokeGroupFunc returns fn wrapped by groupFunc for functions invoked
+// once all constructors were provided. fn is returned as-is if it can't
+// consume values given an order or a key: no constructor of the
+// application produced any, and fn doesn't consume value groups as maps.
+// dig describes wrapped functions as reflect.makeFuncStub in its errors.
+func (app *App) invokeGroupFunc(fn interface{}) interface{} {
+	if app.numGrouped == 0 && !consumesGroupMaps(fn) {
+		return fn
+	}
+	return groupFunc(fn)
+}
+
+// consumesGroupMaps reports whether fn is a function with a parameter
+// struct that consumes a value group as a map.
+func consumesGroupMaps(fn interface{}) bool {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return false
+	}
+
+	for i := 0; i < ft.NumIn(); i++ {
+		t := ft.In(i)
+		if t.Kind() != reflect.Struct || !dig.IsIn(t) {
+			continue
+		}
+		for j := 0; j < t.NumField(); j++ {
+			f := t.Field(j)
+			if f.PkgPath == "" && len(f.Tag.Get("group")) > 0 && isGroupMap(f.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupOut is a result struct with one or more value group fields that
+// were given an order or a key.
+type groupOut struct {
+	// Result struct type presented to dig. This holds all exported fields
+	// of the original struct other than the ones given an order or a key,
+	// followed by a field for the hidden group of each value group with
+	// such fields.
+	DigType reflect.Type
+
+	// Index of each field of the original struct in DigType, or -1 for
+	// unexported fields and fields given an order or a key.
+	DigFields []int
+
+	Fields []groupField
+}
+
+// groupField is a value group field of a result struct that was given an
+// order or a key.
+type groupField struct {
+	Index   int // index of the field in the original struct
+	Hidden  int // index of the field for its hidden group in DigType
+	Order   int
+	Key     string
+	Flatten bool
+}
+
+// newGroupOut returns a groupOut if t is a result struct with one or more
+// value group fields that were given an order with the order tag, or a key
+// with the key tag. It returns nil otherwise.
+func newGroupOut(t reflect.Type) (*groupOut, error) {
+	if t.Kind() != reflect.Struct || !dig.IsOut(t) {
+		return nil, nil
+	}
+
+	o := groupOut{DigFields: make([]int, t.NumField())}
+	var (
+		fields []reflect.StructField
+		hidden []reflect.StructField
+		groups = make(map[string]int) // index in hidden by group name
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		o.DigFields[i] = -1
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if nested, err := newGroupOut(f.Type); err != nil || nested != nil {
+			return nil, fmt.Errorf("field %v: order and key tags are only supported on "+
+				"fields of fx.Out structs returned by constructors", f.Name)
+		}
+
+		gf := groupField{Index: i}
+		if tag, ok := f.Tag.Lookup("order"); ok {
+			order, err := strconv.Atoi(tag)
+			if err != nil {
+				return nil, fmt.Errorf("field %v: invalid order %q: must be an integer", f.Name, tag)
+			}
+			gf.Order = order
+		}
+		gf.Key = f.Tag.Get("key")
+		if gf.Order == 0 && len(gf.Key) == 0 {
+			// Like values without an order.
+			o.DigFields[i] = len(fields)
+			fields = append(fields, f)
+			continue
+		}
+
+		var name string
+		name, gf.Flatten = splitGroup(f.Tag.Get("group"))
+		switch {
+		case len(name) == 0:
+			return nil, fmt.Errorf("field %v: order and key tags are only supported on value group fields", f.Name)
+		case gf.Flatten && len(gf.Key) > 0:
+			return nil, fmt.Errorf("field %v: key tags cannot be used with flattened value groups", f.Name)
+		}
+
+		h, ok := groups[name]
+		if !ok {
+			h = len(hidden)
+			groups[name] = h
+			hidden = append(hidden, reflect.StructField{
+				Name: fmt.Sprintf("FxGrouped%d", h),
+				Type: _typeOfGroupValues,
+				Tag:  reflect.StructTag(fmt.Sprintf(`group:"%v,flatten"`, hiddenGroup(name))),
+			})
+		}
+		gf.Hidden = h
+		o.Fields = append(o.Fields, gf)
+	}
+
+	if len(o.Fields) == 0 {
+		return nil, nil
+	}
+
+	for i := range o.Fields {
+		o.Fields[i].Hidden += len(fields)
+	}
+	o.DigType = reflect.StructOf(append(fields, hidden...))
+	return &o, nil
+}
+
+// Build constructs a value of DigType from the original result struct.
+func (o *groupOut) Build(v reflect.Value, seq int) reflect.Value {
+	result := reflect.New(o.DigType).Elem()
+	for i, j := range o.DigFields {
+		if j >= 0 {
+			result.Field(j).Set(v.Field(i))
+		}
+	}
+
+	var values []groupValue
+	for _, f := range o.Fields {
+		hidden := result.Field(f.Hidden)
+		values = appendGroupValues(values[:0], v.Field(f.Index), f.Flatten, groupValue{
+			Order: f.Order,
+			Key:   f.Key,
+			Seq:   seq,
+		})
+		for _, gv := range values {
+			gv.Index += hidden.Len()
+			hidden.Set(reflect.Append(hidden, reflect.ValueOf(gv)))
+		}
+	}
+	return result
+}
+
+// groupResults returns a constructor with the same behavior as fn, except
+// that the values it produces with an order or a key are provided to the
+// hidden groups of their value groups, along with their order and key.
+//
+// If ann is non-nil and has an order or a key, all values produced by fn
+// are given them in the group of ann, and the returned constructor must be
+// provided to the hidden group returned by hiddenGroup with the flatten
+// option. Otherwise, fn is inspected for result structs with order and key
+// tags.
+//
+// fn is returned as-is if it produces no values with an order or a key.
+func (app *App) groupResults(fn interface{}, ann *Annotated) (interface{}, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fn, nil
+	}
+	ft := fv.Type()
+
+	in := make([]reflect.Type, ft.NumIn())
+	for i := range in {
+		in[i] = ft.In(i)
+	}
+
+	if ann != nil && (ann.Order != 0 || len(ann.Key) > 0) {
+		_, flatten := splitGroup(ann.Group)
+		switch {
+		case len(ann.Group) == 0:
+			return nil, fmt.Errorf("fx.Annotated may specify an Order or a Key only with a Group")
+		case flatten && len(ann.Key) > 0:
+			return nil, fmt.Errorf("fx.Annotated may not specify a Key with a flattened Group")
+		}
+
+		seq := app.nextGroupSeq()
+		newFt := reflect.FuncOf(in, []reflect.Type{_typeOfGroupValues, _typeOfError}, ft.IsVariadic())
+		return reflect.MakeFunc(newFt, func(args []reflect.Value) []reflect.Value {
+			var results []reflect.Value
+			if ft.IsVariadic() {
+				results = fv.CallSlice(args)
+			} else {
+				results = fv.Call(args)
+			}
+
+			err := reflect.Zero(_typeOfError)
+			var values []groupValue
+			for _, r := range results {
+				if r.Type() == _typeOfError {
+					err = r
+					continue
+				}
+				values = appendGroupValues(values, r, flatten, groupValue{
+					Order: ann.Order,
+					Key:   ann.Key,
+					Seq:   seq,
+				})
+			}
+			if !err.IsNil() {
+				values = nil
+			}
+			return []reflect.Value{reflect.ValueOf(values), err}
+		}).Interface(), nil
+	}
+
+	var (
+		outs      = make([]*groupOut, ft.NumOut())
+		out       = make([]reflect.Type, ft.NumOut())
+		hasGroups bool
+	)
+	for i := range out {
+		out[i] = ft.Out(i)
+
+		o, err := newGroupOut(ft.Out(i))
+		if err != nil {
+			return nil, fmt.Errorf("cannot provide the results of %v: %v", ft.Out(i), err)
+		}
+		if o != nil {
+			outs[i] = o
+			out[i] = o.DigType
+			hasGroups = true
+		}
+	}
+	if !hasGroups {
+		return fn, nil
+	}
+
+	seq := app.nextGroupSeq()
+	newFt := reflect.FuncOf(in, out, ft.IsVariadic())
+	return reflect.MakeFunc(newFt, func(args []reflect.Value) []reflect.Value {
+		var results []reflect.Value
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+
+		for i, o := range outs {
+			if o != nil {
+				results[i] = o.Build(results[i], seq)
+			}
+		}
+		return results
+	}).Interface(), nil
+}
+
+func (app *App) nextGroupSeq() int {
+	app.numGrouped++
+	return app.numGrouped
+}
+
+// groupKey identifies a value given a key in its value group.
+type groupKey struct {
+	fxreflect.Key
+	GroupKey string
+}
+
+// checkGroupKeys verifies that the values given a key by the constructor n
+// don't reuse the key of another value of the same group.
+func (app *App) checkGroupKeys(n *graph.Node) error {
+	for i, key := range n.GroupKeys {
+		if len(key) == 0 {
+			continue
+		}
+
+		k := groupKey{Key: n.Results[i], GroupKey: key}
+		if prev, ok := app.groupKeys[k]; ok {
+			return fmt.Errorf("duplicate key %q in value group %v: "+
+				"already provided by %v from:\n%+v",
+				key, n.Results[i], fxreflect.FuncName(prev.Func), prev.Stack)
+		}
+		if app.groupKeys == nil {
+			app.groupKeys = make(map[groupKey]*graph.Node)
+		}
+		app.groupKeys[k] = n
+	}
+	return nil
+}
+
+// resultString formats the given key along with its order and key in its
+// value group, if any. For example,
+//
+//  *http.ServeMux[group = "routes", key = "users", order = 10]
+func resultString(k fxreflect.Key, order int, key string) string {
+	return annotateResult(k.String(), order, key)
+}
+
+// annotateResult adds the given order and key to s, the representation of
+// a value group key. See resultString.
+func annotateResult(s string, order int, key string) string {
+	if len(key) > 0 {
+		s = fmt.Sprintf("%v, key = %q]", strings.TrimSuffix(s, "]"), key)
+	}
+	if order != 0 {
+		s = fmt.Sprintf("%v, order = %d]", strings.TrimSuffix(s, "]"), order)
+	}
+	return s
+}
+
+// resultStrings formats the values produced by the given constructor along
+// with their orders and keys. It returns nil if none of them have either.
+func resultStrings(n *graph.Node) []string {
+	if n.Orders == nil && n.GroupKeys == nil {
+		return nil
+	}
+
+	names := make([]string, len(n.Results))
+	for i, k := range n.Results {
+		order, key := resultAnnotations(n, i)
+		names[i] = resultString(k, order, key)
+	}
+	return names
+}
+
+// resultAnnotations returns the order and the key of the i-th value
+// produced by n in its value group.
+func resultAnnotations(n *graph.Node, i int) (order int, key string) {
+	if n.Orders != nil {
+		order = n.Orders[i]
+	}
+	if n.GroupKeys != nil {
+		key = n.GroupKeys[i]
+	}
+	return order, key
+}
+
+// setGroupAnnotations records the orders and keys given to the values
+// produced by n in their value groups, if any of them were given one.
+func setGroupAnnotations(n *graph.Node, orders []int, keys []string) {
+	for _, order := range orders {
+		if order != 0 {
+			n.Orders = orders
+			break
+		}
+	}
+	for _, key := range keys {
+		if len(key) > 0 {
+			n.GroupKeys = keys
+			break
+		}
+	}
+}
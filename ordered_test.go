@@ -0,0 +1,486 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+func TestOrderedGroups(t *testing.T) {
+	type route struct{ Name string }
+
+	newRoute := func(name string) func() route {
+		return func() route { return route{Name: name} }
+	}
+
+	names := func(routes []route) []string {
+		var names []string
+		for _, r := range routes {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+
+	type params struct {
+		fx.In
+
+		Routes []route `group:"routes"`
+	}
+
+	t.Run("Annotated", func(t *testing.T) {
+		// dig shuffles the values of groups, so try a few times.
+		for i := 0; i < 10; i++ {
+			var p params
+			app := fxtest.New(t,
+				fx.Provide(
+					fx.Annotated{Group: "routes", Order: 3, Target: newRoute("c")},
+					fx.Annotated{Group: "routes", Order: -1, Target: newRoute("a")},
+					fx.Annotated{Group: "routes", Target: newRoute("unordered")},
+					fx.Annotated{Group: "routes", Order: 2, Target: newRoute("b1")},
+					fx.Annotated{Group: "routes", Order: 2, Target: newRoute("b2")},
+				),
+				fx.Populate(&p),
+			)
+			app.RequireStart().RequireStop()
+			assert.Equal(t, []string{"a", "unordered", "b1", "b2", "c"}, names(p.Routes))
+		}
+	})
+
+	t.Run("Flatten", func(t *testing.T) {
+		var p params
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "routes", Order: 2, Target: newRoute("c")},
+				fx.Annotated{
+					Group: "routes,flatten",
+					Order: 1,
+					Target: func() []route {
+						return []route{{Name: "a"}, {Name: "b"}}
+					},
+				},
+			),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"a", "b", "c"}, names(p.Routes))
+	})
+
+	t.Run("ResultStructs", func(t *testing.T) {
+		type result struct {
+			fx.Out
+
+			First  route   `group:"routes" order:"-5"`
+			Last   route   `group:"routes" order:"5"`
+			Middle []route `group:"routes,flatten" order:"0"`
+			Other  route   `group:"routes"`
+		}
+
+		var got []string
+		app := fxtest.New(t,
+			fx.Provide(func() result {
+				return result{
+					First:  route{Name: "first"},
+					Last:   route{Name: "last"},
+					Middle: []route{{Name: "m1"}, {Name: "m2"}},
+					Other:  route{Name: "other"},
+				}
+			}),
+			fx.Provide(func(p params) []string { return names(p.Routes) }),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		require.Len(t, got, 5)
+		assert.Equal(t, "first", got[0])
+		assert.Equal(t, "last", got[4])
+		assert.Contains(t, got, "other")
+	})
+
+	t.Run("SameConstructor", func(t *testing.T) {
+		newA := newRoute("a")
+
+		var p params
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "routes", Order: 1, Target: newA},
+				fx.Annotated{Group: "routes", Order: 2, Target: newA},
+			),
+			fx.Provide(fx.Annotated{Group: "routes", Order: 3, Target: newA}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"a", "a", "a"}, names(p.Routes))
+	})
+
+	t.Run("Eager", func(t *testing.T) {
+		var called bool
+		app := fxtest.New(t,
+			fx.Provide(fx.Annotated{
+				Group: "routes",
+				Order: 1,
+				Eager: true,
+				Target: func() route {
+					called = true
+					return route{}
+				},
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.True(t, called)
+	})
+
+	t.Run("Logs", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fx.New(
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Provide(fx.Annotated{Group: "routes", Order: 10, Target: newRoute("a")}),
+		)
+		require.NoError(t, app.Err())
+
+		var outputs []string
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Provide); ok {
+				outputs = append(outputs, e.OutputTypeNames...)
+			}
+		}
+		assert.Contains(t, outputs, `fx_test.route[group = "routes", order = 10]`)
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		var g fx.Graph
+		app := fxtest.New(t,
+			fx.Provide(fx.Annotated{Group: "routes", Order: 10, Target: newRoute("a")}),
+			fx.Invoke(func(params) {}),
+			fx.Populate(&g),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var found bool
+		for _, n := range g.Nodes {
+			for _, r := range n.Results {
+				if r == `fx_test.route[group = "routes", order = 10]` {
+					found = true
+				}
+			}
+		}
+		assert.True(t, found, "ordered result not found in %v", g.Nodes)
+
+		var edge *fx.GraphEdge
+		for i, e := range g.Edges {
+			if e.Group == "routes" {
+				edge = &g.Edges[i]
+			}
+		}
+		require.NotNil(t, edge)
+		assert.Equal(t, 10, edge.Order)
+		assert.Contains(t, g.Mermaid(), `fx_test.route[group = #quot;routes#quot;, order = 10]`)
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		type missing struct{}
+
+		type mapParams struct {
+			fx.In
+
+			Routes map[string]route `group:"routes"`
+		}
+
+		tests := []struct {
+			desc   string
+			give   fx.Option
+			invoke interface{}
+		}{
+			{
+				desc:   "unordered",
+				give:   fx.Provide(fx.Annotated{Group: "routes", Target: newRoute("a")}),
+				invoke: func(params, *missing) {},
+			},
+			{
+				desc:   "ordered",
+				give:   fx.Provide(fx.Annotated{Group: "routes", Order: 1, Target: newRoute("a")}),
+				invoke: func(params, *missing) {},
+			},
+			{
+				desc:   "map",
+				give:   fx.Provide(fx.Annotated{Group: "routes", Key: "a", Target: newRoute("a")}),
+				invoke: func(mapParams, *missing) {},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := fx.New(fx.NopLogger, tt.give, fx.Invoke(tt.invoke))
+				err := app.Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "missing dependencies for function")
+				assert.Contains(t, err.Error(), "TestOrderedGroups")
+				assert.Contains(t, err.Error(), "ordered_test.go")
+				assert.NotContains(t, err.Error(), "makeFuncStub")
+			})
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			give interface{}
+			want string
+		}{
+			{
+				desc: "order without group",
+				give: fx.Annotated{Name: "foo", Order: 1, Target: newRoute("a")},
+				want: "fx.Annotated may specify an Order or a Key only with a Group",
+			},
+			{
+				desc: "invalid order",
+				give: func() struct {
+					fx.Out
+
+					Route route `group:"routes" order:"first"`
+				} {
+					panic("unreachable")
+				},
+				want: `field Route: invalid order "first": must be an integer`,
+			},
+			{
+				desc: "order without group tag",
+				give: func() struct {
+					fx.Out
+
+					Route route `name:"foo" order:"1"`
+				} {
+					panic("unreachable")
+				},
+				want: "field Route: order and key tags are only supported on value group fields",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := fx.New(fx.NopLogger, fx.Provide(tt.give))
+				err := app.Err()
+				require.Error(t, err)
+				assert.True(t, strings.Contains(err.Error(), tt.want), err.Error())
+			})
+		}
+	})
+}
+
+func TestKeyedGroups(t *testing.T) {
+	type handler struct{ Name string }
+
+	newHandler := func(name string) func() handler {
+		return func() handler { return handler{Name: name} }
+	}
+
+	type params struct {
+		fx.In
+
+		ByKey map[string]handler `group:"handlers"`
+		All   []handler          `group:"handlers"`
+	}
+
+	t.Run("Annotated", func(t *testing.T) {
+		var p params
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "handlers", Key: "users", Target: newHandler("users")},
+				fx.Annotated{Group: "handlers", Key: "orders", Target: newHandler("orders")},
+				fx.Annotated{Group: "handlers", Target: newHandler("unkeyed")},
+			),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, map[string]handler{
+			"users":  {Name: "users"},
+			"orders": {Name: "orders"},
+		}, p.ByKey)
+		assert.Len(t, p.All, 3)
+	})
+
+	t.Run("SameConstructor", func(t *testing.T) {
+		newUsers := newHandler("users")
+
+		var p params
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "handlers", Key: "users", Target: newUsers},
+				fx.Annotated{Group: "handlers", Key: "admins", Target: newUsers},
+			),
+			fx.Provide(fx.Annotated{Group: "handlers", Key: "guests", Target: newUsers}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, map[string]handler{
+			"users":  {Name: "users"},
+			"admins": {Name: "users"},
+			"guests": {Name: "users"},
+		}, p.ByKey)
+	})
+
+	t.Run("SameConstructorEager", func(t *testing.T) {
+		var calls int
+		newUsers := func() handler {
+			calls++
+			return handler{Name: "users"}
+		}
+
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "handlers", Target: newUsers},
+				fx.Annotated{Group: "handlers", Target: newUsers, Eager: true},
+			),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.NotZero(t, calls, "the eager constructor must run")
+	})
+
+	t.Run("ResultStructs", func(t *testing.T) {
+		type result struct {
+			fx.Out
+
+			Users  handler `group:"handlers" key:"users"`
+			Orders handler `group:"handlers" key:"orders" order:"-1"`
+		}
+
+		var p params
+		app := fxtest.New(t,
+			fx.Provide(func() result {
+				return result{Users: handler{Name: "users"}, Orders: handler{Name: "orders"}}
+			}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Equal(t, map[string]handler{
+			"users":  {Name: "users"},
+			"orders": {Name: "orders"},
+		}, p.ByKey)
+		require.Len(t, p.All, 2)
+		assert.Equal(t, "orders", p.All[0].Name)
+	})
+
+	t.Run("EmptyGroup", func(t *testing.T) {
+		var p params
+		app := fxtest.New(t, fx.Populate(&p))
+		defer app.RequireStart().RequireStop()
+
+		assert.NotNil(t, p.ByKey)
+		assert.Empty(t, p.ByKey)
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		var g fx.Graph
+		app := fxtest.New(t,
+			fx.Provide(fx.Annotated{Group: "handlers", Key: "users", Target: newHandler("users")}),
+			fx.Invoke(func(params) {}),
+			fx.Populate(&g),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var edge *fx.GraphEdge
+		for i, e := range g.Edges {
+			if e.Group == "handlers" {
+				edge = &g.Edges[i]
+			}
+		}
+		require.NotNil(t, edge)
+		assert.Equal(t, "users", edge.Key)
+		assert.Contains(t, g.Mermaid(), `fx_test.handler[group = #quot;handlers#quot;, key = #quot;users#quot;]`)
+	})
+
+	t.Run("DuplicateKeys", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Provide(fx.Annotated{Group: "handlers", Key: "users", Target: newHandler("a")}),
+			fx.Provide(fx.Annotated{Group: "handlers", Key: "users", Target: newHandler("b")}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		var provideErr *fx.ProvideError
+		require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+		assert.Equal(t, "go.uber.org/fx_test.TestKeyedGroups.func1.1()",
+			fxreflect.FuncName(provideErr.Constructor.(fx.Annotated).Target))
+		assert.Contains(t, provideErr.Stack.String(), "ordered_test.go")
+
+		assert.Contains(t, err.Error(),
+			`duplicate key "users" in value group fx_test.handler[group = "handlers"]`)
+		assert.Regexp(t, `already provided by go.uber.org/fx_test.TestKeyedGroups.func1.1\(\) from:\n`+
+			`go.uber.org/fx_test.TestKeyedGroups.func\d+\n\t.+ordered_test.go:\d+\n`, err.Error())
+		assert.Equal(t, 2, strings.Count(err.Error(), "ordered_test.go"))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			give interface{}
+			want string
+		}{
+			{
+				desc: "key without group",
+				give: fx.Annotated{Name: "foo", Key: "users", Target: newHandler("a")},
+				want: "fx.Annotated may specify an Order or a Key only with a Group",
+			},
+			{
+				desc: "key with flatten",
+				give: fx.Annotated{
+					Group:  "handlers,flatten",
+					Key:    "users",
+					Target: func() []handler { return nil },
+				},
+				want: "fx.Annotated may not specify a Key with a flattened Group",
+			},
+			{
+				desc: "key tag with flatten",
+				give: func() struct {
+					fx.Out
+
+					Handlers []handler `group:"handlers,flatten" key:"users"`
+				} {
+					panic("unreachable")
+				},
+				want: "field Handlers: key tags cannot be used with flattened value groups",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := fx.New(fx.NopLogger, fx.Provide(tt.give))
+				err := app.Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+			})
+		}
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+// RecoverFromPanics makes the application recover from panics in
+// constructors, functions passed to fx.Invoke, and lifecycle hooks, and
+// treat them as if the function returned a PanicError. Without this
+// option, a panic in any of these crashes the process.
+//
+// Panics are reported through the same paths as other errors: a panic in a
+// constructor or invoked function fails New, and a panic in an OnStart
+// hook fails Start and rolls back the hooks that already ran.
+func RecoverFromPanics() Option {
+	return recoverFromPanicsOption{Stack: fxreflect.CallerStack(1, 0)}
+}
+
+type recoverFromPanicsOption struct {
+	Stack fxreflect.Stack
+}
+
+func (recoverFromPanicsOption) apply(app *App) {
+	app.recoverFromPanics = true
+}
+
+func (o recoverFromPanicsOption) visit(v *optionVisitor) {
+	v.report(o, "fx.RecoverFromPanics", o.Stack)
+}
+
+func (recoverFromPanicsOption) String() string {
+	return "fx.RecoverFromPanics()"
+}
+
+// PanicError is reported in place of a panic in a constructor, a function
+// passed to fx.Invoke, or a lifecycle hook if the application was built
+// with RecoverFromPanics.
+//
+// Use errors.As to retrieve a PanicError from the error returned by
+// App.Err, App.Start, or App.Stop.
+type PanicError struct {
+	// Function is the function that panicked.
+	Function interface{}
+
+	// Caller is the name of the function that provided, invoked, or
+	// appended the function that panicked, and File and Line are the
+	// location in that function where it did so.
+	Caller string
+	File   string
+	Line   int
+
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v panicked: %v", fxreflect.FuncName(e.Function), e.Value)
+}
+
+// recoverFunc wraps fn so that it returns a PanicError if it panics. orig
+// is the function as it was passed to Fx, and stack is where it was passed
+// from. If fn doesn't return an error, the returned function returns one
+// in addition to the results of fn.
+func recoverFunc(orig interface{}, stack fxreflect.Stack, fn interface{}) interface{} {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fn
+	}
+	ft := fv.Type()
+
+	in := make([]reflect.Type, ft.NumIn())
+	for i := range in {
+		in[i] = ft.In(i)
+	}
+	out := make([]reflect.Type, ft.NumOut())
+	for i := range out {
+		out[i] = ft.Out(i)
+	}
+	returnsErr := len(out) > 0 && out[len(out)-1] == _typeOfError
+	if !returnsErr {
+		out = append(out, _typeOfError)
+	}
+
+	newFt := reflect.FuncOf(in, out, ft.IsVariadic())
+	return reflect.MakeFunc(newFt, func(args []reflect.Value) (results []reflect.Value) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			var err error = newPanicError(orig, stack, v)
+			results = make([]reflect.Value, len(out))
+			for i, t := range out {
+				results[i] = reflect.Zero(t)
+			}
+			results[len(results)-1] = reflect.ValueOf(&err).Elem()
+		}()
+
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+		if !returnsErr {
+			results = append(results, reflect.Zero(_typeOfError))
+		}
+		return results
+	}).Interface()
+}
+
+// newPanicError builds a PanicError for a panic in fn, which was passed to
+// Fx from the given stack.
+func newPanicError(fn interface{}, stack fxreflect.Stack, v interface{}) *PanicError {
+	err := &PanicError{
+		Function: fn,
+		Value:    v,
+		Stack:    string(debug.Stack()),
+	}
+	if f, ok := stack.Caller(); ok {
+		err.Caller = f.Function
+		err.File = f.File
+		err.Line = f.Line
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestRecoverFromPanics(t *testing.T) {
+	type A struct{}
+
+	t.Run("Constructor", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.RecoverFromPanics(),
+			fx.Provide(func() *A { panic("great sadness") }),
+			fx.Invoke(func(*A) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "panicked: great sadness")
+
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError")
+
+		var panicErr *fx.PanicError
+		require.True(t, errors.As(err, &panicErr), "expected a PanicError")
+		assert.Equal(t, "great sadness", panicErr.Value)
+		assert.Contains(t, panicErr.Caller, "TestRecoverFromPanics")
+		assert.Contains(t, panicErr.File, "panic_test.go")
+		assert.Contains(t, panicErr.Stack, "panic_test.go")
+	})
+
+	t.Run("Invoke", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func() { panic("great sadness") }),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		var panicErr *fx.PanicError
+		require.True(t, errors.As(err, &panicErr), "expected a PanicError")
+		assert.Equal(t, "great sadness", panicErr.Value)
+		assert.Contains(t, panicErr.File, "panic_test.go")
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func(*A) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing dependencies for function")
+		assert.Contains(t, err.Error(), "TestRecoverFromPanics")
+		assert.Contains(t, err.Error(), "panic_test.go")
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+	})
+
+	t.Run("OnStart", func(t *testing.T) {
+		stopped := false
+		app := fxtest.New(t,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnStop: func(context.Context) error {
+						stopped = true
+						return nil
+					},
+				})
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error { panic("great sadness") },
+				})
+			}),
+		)
+
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.True(t, stopped, "expected Start to roll back")
+
+		var lifecycleErr *fx.LifecycleError
+		require.True(t, errors.As(err, &lifecycleErr), "expected a LifecycleError")
+		assert.Equal(t, "OnStart", lifecycleErr.Hook)
+
+		var panicErr *fx.PanicError
+		require.True(t, errors.As(err, &panicErr), "expected a PanicError")
+		assert.Equal(t, "great sadness", panicErr.Value)
+		assert.Contains(t, panicErr.File, "panic_test.go")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.PanicsWithValue(t, "great sadness", func() {
+			fx.New(
+				fx.NopLogger,
+				fx.Invoke(func() { panic("great sadness") }),
+			)
+		})
+	})
+}
@@ -23,6 +23,8 @@ package fx
 import (
 	"fmt"
 	"reflect"
+
+	"go.uber.org/fx/internal/fxreflect"
 )
 
 // Populate sets targets with values from the dependency injection container
@@ -34,6 +36,8 @@ import (
 // constructor wiring to build a few structs, but then extract those structs
 // for further testing.
 func Populate(targets ...interface{}) Option {
+	stack := fxreflect.CallerStack(1, 0)
+
 	// Validate all targets are non-nil pointers.
 	targetTypes := make([]reflect.Type, len(targets))
 	for i, t := range targets {
@@ -63,7 +67,27 @@ func Populate(targets ...interface{}) Option {
 		}
 		return nil
 	})
-	return Invoke(fn.Interface())
+	return populateOption{
+		Targets: targets,
+		Invoke:  invokeOption{Targets: []interface{}{fn.Interface()}, Stack: stack},
+	}
+}
+
+type populateOption struct {
+	Targets []interface{}
+	Invoke  invokeOption
+}
+
+func (o populateOption) apply(app *App) {
+	o.Invoke.apply(app)
+}
+
+func (o populateOption) visit(v *optionVisitor) {
+	v.report(o, "fx.Populate", o.Invoke.Stack, o.Targets...)
+}
+
+func (o populateOption) String() string {
+	return o.Invoke.String()
 }
 
 func invokeErr(err error) Option {
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/multierr"
+)
+
+// ProvideStruct registers the exported methods of a struct as
+// constructors, as if they were passed to Provide. This lets a module be
+// defined as a struct that holds the configuration its constructors share.
+//
+//  type DBModule struct {
+//    DSN string
+//  }
+//
+//  func (m *DBModule) ProvideConn(log *zap.Logger) (*sql.DB, error) {
+//    // ...
+//  }
+//
+//  fx.ProvideStruct(&DBModule{DSN: dsn})
+//
+// module must be a struct or a non-nil pointer to a struct. Pass a pointer
+// to register the methods with pointer receivers as well. All exported
+// methods are registered, so they must all be valid constructors.
+//
+// Events and errors refer to the methods by name, like
+// (*DBModule).ProvideConn.
+func ProvideStruct(module interface{}) Option {
+	o := provideStructOption{
+		Module: module,
+		Stack:  fxreflect.CallerStack(1, 0),
+	}
+
+	v := reflect.ValueOf(module)
+	isStruct := v.Kind() == reflect.Struct ||
+		v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct
+	if !isStruct {
+		o.err = fmt.Errorf("fx.ProvideStruct received %v (type %T) from:\n%+v"+
+			"Failed: must provide a struct or a non-nil pointer to a struct",
+			module, module, o.Stack)
+		return o
+	}
+
+	t := v.Type()
+	if t.NumMethod() == 0 {
+		o.err = fmt.Errorf("fx.ProvideStruct received %v from:\n%+v"+
+			"Failed: %v has no exported methods",
+			t, o.Stack, t)
+		return o
+	}
+
+	for i := 0; i < t.NumMethod(); i++ {
+		o.Targets = append(o.Targets, v.Method(i).Interface())
+		o.Methods = append(o.Methods, t.Method(i).Func.Interface())
+	}
+	return o
+}
+
+type provideStructOption struct {
+	Module  interface{}
+	Targets []interface{}
+	Stack   fxreflect.Stack
+
+	// Method expressions of Targets, like (*DBModule).ProvideConn. reflect
+	// builds all method values with the same stub, so Targets are reported
+	// as these instead.
+	Methods []interface{}
+
+	err error // set if Module is not a valid module
+}
+
+func (o provideStructOption) apply(app *App) {
+	if o.err != nil {
+		app.err = multierr.Append(app.err, o.err)
+		return
+	}
+
+	for i, target := range o.Targets {
+		app.provides = append(app.provides, provide{
+			Target: target,
+			Method: o.Methods[i],
+			Stack:  o.Stack,
+			Key:    newProvideKey(target),
+			Source: app.source(o.Stack),
+		})
+	}
+}
+
+func (o provideStructOption) visit(v *optionVisitor) {
+	v.report(o, "fx.ProvideStruct", o.Stack, o.Targets...)
+}
+
+func (o provideStructOption) String() string {
+	return fmt.Sprintf("fx.ProvideStruct(%T)", o.Module)
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+type structConfig struct{ dsn string }
+
+type structConn struct{ dsn string }
+
+type structModule struct {
+	DSN string
+}
+
+func (m structModule) ProvideConfig() *structConfig {
+	return &structConfig{dsn: m.DSN}
+}
+
+func (m *structModule) ProvideConn(cfg *structConfig) (*structConn, error) {
+	if len(cfg.dsn) == 0 {
+		return nil, errors.New("no DSN")
+	}
+	return &structConn{dsn: cfg.dsn}, nil
+}
+
+type emptyModule struct{}
+
+func TestProvideStruct(t *testing.T) {
+	t.Run("ProvidesMethods", func(t *testing.T) {
+		var conn *structConn
+		app := fxtest.New(t,
+			fx.ProvideStruct(&structModule{DSN: "foo"}),
+			fx.Populate(&conn),
+		)
+		defer app.RequireStart().RequireStop()
+
+		require.NotNil(t, conn)
+		assert.Equal(t, "foo", conn.dsn)
+	})
+
+	t.Run("ValueReceivers", func(t *testing.T) {
+		var cfg *structConfig
+		app := fxtest.New(t,
+			fx.ProvideStruct(structModule{DSN: "foo"}),
+			fx.Populate(&cfg),
+		)
+		defer app.RequireStart().RequireStop()
+
+		require.NotNil(t, cfg)
+		assert.Equal(t, "foo", cfg.dsn)
+	})
+
+	t.Run("EventsNameMethods", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.ProvideStruct(&structModule{DSN: "foo"}),
+			fx.Invoke(func(*structConn) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var provided, ran []string
+		for _, e := range spy.Events() {
+			switch e := e.(type) {
+			case *fxevent.Provide:
+				provided = append(provided, fxreflect.FuncName(e.Constructor))
+			case *fxevent.Run:
+				ran = append(ran, fxreflect.FuncName(e.Constructor))
+			}
+		}
+		assert.Subset(t, provided, []string{
+			"go.uber.org/fx_test.(*structModule).ProvideConfig()",
+			"go.uber.org/fx_test.(*structModule).ProvideConn()",
+		})
+		assert.Equal(t, []string{
+			"go.uber.org/fx_test.(*structModule).ProvideConfig()",
+			"go.uber.org/fx_test.(*structModule).ProvideConn()",
+		}, ran)
+	})
+
+	t.Run("ErrorsNameMethods", func(t *testing.T) {
+		app := NewForTest(t,
+			fx.ProvideStruct(&structModule{}),
+			fx.Invoke(func(*structConn) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `go.uber.org/fx_test.(*structModule).ProvideConn() (`)
+		assert.Contains(t, err.Error(), "fx/providestruct_test.go")
+		assert.Contains(t, err.Error(), "no DSN")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			give interface{}
+			want string
+		}{
+			{
+				desc: "nil",
+				give: nil,
+				want: "fx.ProvideStruct received <nil> (type <nil>)",
+			},
+			{
+				desc: "nil pointer",
+				give: (*structModule)(nil),
+				want: "fx.ProvideStruct received <nil> (type *fx_test.structModule)",
+			},
+			{
+				desc: "not a struct",
+				give: 42,
+				want: "fx.ProvideStruct received 42 (type int)",
+			},
+			{
+				desc: "no methods",
+				give: &emptyModule{},
+				want: "*fx_test.emptyModule has no exported methods",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := NewForTest(t, fx.ProvideStruct(tt.give))
+				err := app.Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+				assert.Contains(t, err.Error(), "fx/providestruct_test.go")
+			})
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"reflect"
+	"time"
+
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/internal/graph"
+)
+
+// wrapConstructor wraps constructors provided by the user with lazyFunc,
+// groupFunc, and recoverFunc if necessary, and with runFunc. n is the
+// constructor as recorded in the graph.
+//
+// Values supplied with fx.Supply or fx.Default, and constructors provided by
+// Fx itself, are left unchanged.
+func (app *App) wrapConstructor(p provide, n *graph.Node, target interface{}) interface{} {
+	if p.IsSupply || p.IsDefault || p.IsBuiltin {
+		return target
+	}
+	target = groupFunc(app.lazyFunc(target))
+	if app.recoverFromPanics {
+		target = recoverFunc(n.Func, p.Stack, target)
+	}
+	return app.runFunc(n, target)
+}
+
+// runFunc wraps the given constructor so that an fxevent.Run event is
+// logged every time it runs. n is the constructor as recorded in the graph,
+// and target is the function that will be provided to the container.
+//
+// Errors returned by the constructor are reported to the container as
+// runErrors, so that they can be described in terms of n.
+func (app *App) runFunc(n *graph.Node, target interface{}) interface{} {
+	fv := reflect.ValueOf(target)
+	if fv.Kind() != reflect.Func {
+		return target
+	}
+	ft := fv.Type()
+
+	returnsErr := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == _typeOfError
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		start := time.Now()
+		var results []reflect.Value
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+
+		var err error
+		if returnsErr {
+			if err, _ = results[len(results)-1].Interface().(error); err != nil {
+				var runErr error = &runError{node: n, err: err}
+				results[len(results)-1] = reflect.ValueOf(&runErr).Elem()
+			}
+		}
+		app.log.LogEvent(&fxevent.Run{
+			Constructor: n.Func,
+			Runtime:     time.Since(start),
+			Err:         err,
+		})
+		return results
+	}).Interface()
+}
+
+// runError is returned to the container in place of the error returned by
+// a function that Fx provided on behalf of the constructor node. The
+// container describes functions built by Fx as reflect.makeFuncStub, so
+// containerError uses node instead.
+type runError struct {
+	node *graph.Node
+	err  error
+}
+
+func (e *runError) Error() string { return e.err.Error() }
+
+// Unwrap returns the error returned by the constructor.
+func (e *runError) Unwrap() error { return e.err }
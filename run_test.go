@@ -0,0 +1,157 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/internal/fxreflect"
+)
+
+func TestRun(t *testing.T) {
+	type A struct{}
+	type B struct{}
+	type C struct{}
+
+	runs := func(spy *fxlog.Spy) []*fxevent.Run {
+		var runs []*fxevent.Run
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Run); ok {
+				runs = append(runs, e)
+			}
+		}
+		return runs
+	}
+
+	t.Run("EmitsEvents", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		newA := func() *A { return &A{} }
+		newB := func(*A) *B { return &B{} }
+
+		app := fxtest.New(t,
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Provide(newA, fx.Annotated{Name: "b", Target: newB}),
+			fx.Supply(&C{}),
+			fx.Invoke(func(p struct {
+				fx.In
+
+				B *B `name:"b"`
+				C *C
+			}) {
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		got := runs(spy)
+		require.Len(t, got, 2)
+		assert.Equal(t, fxreflect.FuncName(newA), fxreflect.FuncName(got[0].Constructor))
+		assert.Equal(t, fxreflect.FuncName(newB), fxreflect.FuncName(got[1].Constructor))
+		for _, e := range got {
+			assert.NoError(t, e.Err)
+		}
+	})
+
+	t.Run("ReportsErrors", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fx.New(
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Provide(func() (*A, error) { return nil, errors.New("great sadness") }),
+			fx.Invoke(func(*A) {}),
+		)
+		require.Error(t, app.Err())
+
+		got := runs(spy)
+		require.Len(t, got, 1)
+		require.Error(t, got[0].Err)
+		assert.Contains(t, got[0].Err.Error(), "great sadness")
+	})
+
+	t.Run("NamesConstructorsInErrors", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			give []fx.Option
+			want string
+		}{
+			{
+				desc: "ConstructorFails",
+				give: []fx.Option{
+					fx.Provide(func() (*A, error) { return nil, errors.New("great sadness") }),
+					fx.Invoke(func(*A) {}),
+				},
+				want: `received non-nil error from function go.uber.org/fx_test.TestRun.func`,
+			},
+			{
+				desc: "MissingDependency",
+				give: []fx.Option{
+					fx.Provide(func(*B) *A { return &A{} }),
+					fx.Invoke(func(*A) {}),
+				},
+				want: `missing dependencies for function go.uber.org/fx_test.TestRun.func`,
+			},
+			{
+				desc: "Cycle",
+				give: []fx.Option{
+					fx.Provide(func(*B) *A { return &A{} }),
+					fx.Provide(func(*A) *B { return &B{} }),
+					fx.Invoke(func(*A) {}),
+				},
+				want: `*fx_test.A provided by go.uber.org/fx_test.TestRun.func`,
+			},
+			{
+				desc: "AlreadyProvided",
+				give: []fx.Option{
+					fx.Provide(func() *A { return &A{} }),
+					fx.Provide(func() *A { return &A{} }),
+				},
+				want: `already provided by go.uber.org/fx_test.TestRun.func`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := NewForTest(t, tt.give...)
+				err := app.Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+				assert.NotContains(t, err.Error(), "makeFuncStub")
+			})
+		}
+	})
+
+	t.Run("NamesConstructorsInDotGraph", func(t *testing.T) {
+		var g fx.DotGraph
+		app := fxtest.New(t,
+			fx.Provide(func() *A { return &A{} }),
+			fx.Populate(&g),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.Contains(t, string(g), `label = "go.uber.org/fx_test";`)
+		assert.NotContains(t, string(g), "makeFuncStub")
+	})
+}
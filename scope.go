@@ -0,0 +1,563 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/dig"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+	"go.uber.org/multierr"
+)
+
+// RequestScoped registers constructors whose values are built anew for
+// every request scope, rather than once for the application. Request-scoped
+// constructors may depend on any type provided to the application, on
+// other request-scoped types, and on the context.Context of the request.
+// They're not called unless a function invoked in a scope requests their
+// results.
+//
+// Applications built with RequestScoped provide a *Scopes to the container
+// that begins a scope for each request:
+//
+//  fx.New(
+//    fx.Provide(newDB),
+//    fx.RequestScoped(newRequestLogger, newTx),
+//    fx.Invoke(func(mux *http.ServeMux, scopes *fx.Scopes) {
+//      mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//        scope, err := scopes.Begin(r.Context())
+//        ...
+//        defer scope.End(r.Context())
+//
+//        err = scope.Invoke(func(log *RequestLogger, tx *sql.Tx) error {
+//          ...
+//        })
+//      })
+//    }),
+//  )
+//
+// Request-scoped constructors may append hooks to the Lifecycle of the
+// scope. OnStart hooks run as soon as the constructor that appended them
+// returns, and OnStop hooks run when the scope ends, so they're suited to
+// tearing down per-request values like transactions.
+//
+// Request-scoped constructors must be plain functions: they cannot be
+// annotated or return fx.Out structs, and request-scoped values must be
+// requested as parameters rather than fields of fx.In structs.
+func RequestScoped(constructors ...interface{}) Option {
+	return requestScopedOption{
+		Targets: constructors,
+		Stack:   fxreflect.CallerStack(1, 0),
+	}
+}
+
+type requestScopedOption struct {
+	Targets []interface{}
+	Stack   fxreflect.Stack
+}
+
+func (o requestScopedOption) apply(app *App) {
+	for _, target := range o.Targets {
+		app.scoped = append(app.scoped, provide{
+			Target: target,
+			Stack:  o.Stack,
+			Key:    newProvideKey(target),
+			Source: app.source(o.Stack),
+		})
+	}
+
+	if app.scopes == nil {
+		s := &Scopes{app: app}
+		app.scopes = s
+		app.provides = append(app.provides, provide{
+			Target:    func() *Scopes { return s },
+			Stack:     o.Stack,
+			IsBuiltin: true,
+		})
+	}
+}
+
+func (o requestScopedOption) visit(v *optionVisitor) {
+	v.report(o, "fx.RequestScoped", o.Stack, o.Targets...)
+}
+
+func (o requestScopedOption) String() string {
+	items := make([]string, len(o.Targets))
+	for i, c := range o.Targets {
+		items[i] = fxreflect.FuncName(c)
+	}
+	return fmt.Sprintf("fx.RequestScoped(%s)", strings.Join(items, ", "))
+}
+
+var (
+	_typeOfContext   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	_typeOfLifecycle = reflect.TypeOf((*Lifecycle)(nil)).Elem()
+)
+
+// Scopes begins request scopes. It's available in the container if the
+// application was built with RequestScoped.
+type Scopes struct {
+	app *App
+
+	// Request-scoped constructors, indexed by the types they produce.
+	// context.Context and Lifecycle, which every scope holds from the
+	// start, map to nil. Set when the application is built and read-only
+	// afterwards.
+	providers map[reflect.Type]*scopedConstructor
+}
+
+// scopedConstructor is a request-scoped constructor, prepared once to be
+// called in any scope.
+type scopedConstructor struct {
+	Func    *scopeFunc
+	Results []reflect.Type // in order, without the error
+}
+
+// provideScoped prepares the request-scoped constructors, if any, and
+// records them in the graph so that the values they depend on are known to
+// be used.
+func (app *App) provideScoped() {
+	s := app.scopes
+	if app.err != nil || s == nil {
+		return
+	}
+
+	s.providers = map[reflect.Type]*scopedConstructor{
+		_typeOfContext:   nil,
+		_typeOfLifecycle: nil,
+	}
+
+	// Like constructors provided to the application, request-scoped
+	// constructors included more than once are ignored.
+	scoped := app.scoped[:0]
+	sources := make(map[provideKey]fxreflect.Stack, len(app.scoped))
+	for _, p := range app.scoped {
+		if p.Key != (provideKey{}) {
+			if source, ok := sources[p.Key]; ok {
+				app.log.LogEvent(&fxevent.Duplicate{
+					Constructor:        p.Target,
+					Stacktrace:         fmt.Sprintf("%+v", p.Source),
+					PreviousStacktrace: fmt.Sprintf("%+v", source),
+				})
+				continue
+			}
+			sources[p.Key] = p.Source
+		}
+		scoped = append(scoped, p)
+	}
+	app.scoped = scoped
+
+	// All results are recorded first so that constructors can tell which
+	// of their parameters are request-scoped, regardless of order.
+	constructors := make([]*scopedConstructor, len(app.scoped))
+	for i, p := range app.scoped {
+		results, err := s.check(p.Target)
+		if err != nil {
+			app.err = newScopedError(p, err)
+			return
+		}
+
+		c := &scopedConstructor{Results: results}
+		for _, t := range results {
+			s.providers[t] = c
+		}
+		constructors[i] = c
+	}
+
+	for i, p := range app.scoped {
+		target, err := envFunc(p.Target)
+		if err == nil {
+			if app.recoverFromPanics {
+				target = recoverFunc(p.Target, p.Stack, target)
+			}
+			constructors[i].Func, err = s.newFunc(p.Target, target)
+		}
+		if err != nil {
+			app.err = newScopedError(p, err)
+			return
+		}
+
+		keys := make([]fxreflect.Key, len(constructors[i].Results))
+		for j, t := range constructors[i].Results {
+			keys[j] = fxreflect.Key{Type: t}
+		}
+		app.graph.AddScoped(&graph.Node{
+			Func:    p.Target,
+			Stack:   p.Stack,
+			Params:  fxreflect.InspectSignature(target).Params,
+			Results: keys,
+		})
+	}
+}
+
+// check verifies that target can be provided with RequestScoped, alongside
+// the application's constructors and the request-scoped ones recorded so
+// far, and returns the types it produces.
+func (s *Scopes) check(target interface{}) ([]reflect.Type, error) {
+	if _, ok := target.(Annotated); ok {
+		return nil, errors.New("request-scoped constructors cannot be annotated")
+	}
+
+	ft := reflect.TypeOf(target)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("must provide constructor function, got %v (type %T)", target, target)
+	}
+
+	var results []reflect.Type
+	for i := 0; i < ft.NumOut(); i++ {
+		t := ft.Out(i)
+		switch {
+		case t == _typeOfError:
+			if i != ft.NumOut()-1 {
+				return nil, errors.New("only the last result of a request-scoped constructor may be an error")
+			}
+			continue
+		case dig.IsOut(t):
+			return nil, errors.New("request-scoped constructors cannot return fx.Out structs")
+		}
+
+		k := fxreflect.Key{Type: t}
+		if _, ok := s.providers[t]; ok || len(s.app.graph.Providers(k)) > 0 {
+			return nil, fmt.Errorf("cannot provide %v: already provided", k)
+		}
+		results = append(results, t)
+	}
+	if len(results) == 0 {
+		return nil, errors.New("request-scoped constructors must produce at least one value")
+	}
+	return results, nil
+}
+
+// newScopedError reports that the request-scoped constructor p could not be
+// provided.
+func newScopedError(p provide, err error) error {
+	return &ProvideError{
+		Constructor: p.Target,
+		Stack:       p.Stack,
+		Err:         err,
+		option:      "fx.RequestScoped",
+	}
+}
+
+// Begin begins a request scope for the given context. The scope resolves
+// request-scoped types anew, and all other types from the application.
+// Callers must end the scope once the request has been handled.
+func (s *Scopes) Begin(ctx context.Context) (*Scope, error) {
+	if err := s.app.err; err != nil {
+		return nil, err
+	}
+
+	scope := &Scope{
+		scopes: s,
+		ctx:    ctx,
+		values: make(map[reflect.Type]reflect.Value, len(s.providers)),
+	}
+	lc := Lifecycle(&scope.lifecycle)
+	scope.values[_typeOfContext] = reflect.ValueOf(&ctx).Elem()
+	scope.values[_typeOfLifecycle] = reflect.ValueOf(&lc).Elem()
+	return scope, nil
+}
+
+// Scope resolves the values of a single request. See RequestScoped.
+type Scope struct {
+	scopes    *Scopes
+	ctx       context.Context
+	lifecycle scopeLifecycle
+
+	mu sync.Mutex // serializes Invoke and End
+
+	// Request-scoped values built so far, and the constructors being
+	// called, to detect cycles.
+	values   map[reflect.Type]reflect.Value
+	building map[*scopedConstructor]struct{}
+	ended    bool
+}
+
+// Invoke calls the given function with arguments resolved from the scope,
+// the same way functions passed to Invoke are. Request-scoped values are
+// built at most once per scope. Invoke reports the error returned by the
+// function, if any, and the errors of any OnStart hooks appended while
+// resolving its arguments.
+func (s *Scope) Invoke(fn interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stack := fxreflect.CallerStack(1, 0)
+	if err := s.invoke(fn, stack); err != nil {
+		return &InvokeError{
+			Function: fn,
+			Stack:    stack,
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+func (s *Scope) invoke(fn interface{}, stack fxreflect.Stack) error {
+	if s.ended {
+		return errors.New("the scope has ended")
+	}
+
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return fmt.Errorf("can't invoke non-function %v (type %T)", fn, fn)
+	}
+
+	target, err := envFunc(fn)
+	if err != nil {
+		return err
+	}
+	if s.scopes.app.recoverFromPanics {
+		target = recoverFunc(fn, stack, target)
+	}
+
+	f, err := s.scopes.newFunc(fn, target)
+	if err != nil {
+		return err
+	}
+	results, err := f.call(s)
+	if err == nil {
+		err = resultError(results)
+	}
+	if err == nil {
+		// Hooks may also be appended by the function itself.
+		err = s.lifecycle.start(s.ctx)
+	}
+	return err
+}
+
+// value returns the request-scoped value of type t, building it if it
+// wasn't built yet. Callers must hold mu.
+func (s *Scope) value(t reflect.Type) (reflect.Value, error) {
+	if v, ok := s.values[t]; ok {
+		return v, nil
+	}
+
+	c := s.scopes.providers[t]
+	fn := c.Func.Orig
+	if _, ok := s.building[c]; ok {
+		return reflect.Value{}, fmt.Errorf("cycle detected: %v depends on itself", fxreflect.FuncName(fn))
+	}
+	if s.building == nil {
+		s.building = make(map[*scopedConstructor]struct{})
+	}
+	s.building[c] = struct{}{}
+	results, err := c.Func.call(s)
+	delete(s.building, c)
+
+	if err == nil {
+		err = resultError(results)
+	}
+	if err == nil {
+		err = s.lifecycle.start(s.ctx)
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("could not build %v with %v: %w", t, fxreflect.FuncName(fn), err)
+	}
+
+	// check ensured that only the last result may be an error.
+	for i, t := range c.Results {
+		s.values[t] = results[i]
+	}
+	return s.values[t], nil
+}
+
+// End ends the scope, running the OnStop hooks appended to its Lifecycle
+// in reverse order. Ending a scope more than once does nothing.
+func (s *Scope) End(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return nil
+	}
+	s.ended = true
+	return s.lifecycle.stop(ctx)
+}
+
+// scopeLifecycle is the Lifecycle of a scope. Scopes start the hooks
+// appended by each constructor once it returns.
+type scopeLifecycle struct {
+	mu      sync.Mutex
+	pending []Hook // appended since the hooks were last started
+	started []Hook // in the order their OnStart hooks ran
+}
+
+var _ Lifecycle = (*scopeLifecycle)(nil)
+
+func (l *scopeLifecycle) Append(h Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending = append(l.pending, h)
+}
+
+// start runs the OnStart hooks appended since it was last called, stopping
+// at the first that fails.
+func (l *scopeLifecycle) start(ctx context.Context) error {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	for _, h := range pending {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				return err
+			}
+		}
+
+		l.mu.Lock()
+		l.started = append(l.started, h)
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// stop runs the OnStop hooks of the hooks that started, in reverse order.
+func (l *scopeLifecycle) stop(ctx context.Context) error {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+
+	var err error
+	for i := len(started) - 1; i >= 0; i-- {
+		if h := started[i]; h.OnStop != nil {
+			err = multierr.Append(err, h.OnStop(ctx))
+		}
+	}
+	return err
+}
+
+// scopeFunc is a function called in a scope. Its request-scoped
+// parameters are resolved by the scope, and all others by the application.
+type scopeFunc struct {
+	Orig   interface{}    // function as given by the user
+	Func   reflect.Value  // function called with all parameters
+	Scoped []reflect.Type // type of each request-scoped parameter, or nil
+
+	// Function invoked on the container of the application to resolve the
+	// parameters that aren't request-scoped, and the arguments it received
+	// in order. parent is nil if all parameters are request-scoped. args is
+	// guarded by the containerMu of the application. parentNode describes
+	// parent to containerError.
+	parent     interface{}
+	parentNode *graph.Node
+	args       []reflect.Value
+}
+
+// newFunc prepares fn, the function orig as wrapped by Fx, to be called in
+// a scope.
+func (s *Scopes) newFunc(orig, fn interface{}) (*scopeFunc, error) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	f := &scopeFunc{
+		Orig:   orig,
+		Func:   fv,
+		Scoped: make([]reflect.Type, ft.NumIn()),
+	}
+
+	var in []reflect.Type
+	for i := range f.Scoped {
+		t := ft.In(i)
+		variadic := ft.IsVariadic() && i == ft.NumIn()-1
+		if _, ok := s.providers[t]; ok && !variadic {
+			f.Scoped[i] = t
+			continue
+		}
+
+		if dig.IsIn(t) {
+			for j := 0; j < t.NumField(); j++ {
+				field := t.Field(j)
+				if _, ok := s.providers[field.Type]; ok {
+					return nil, fmt.Errorf(
+						"field %v of %v is request-scoped: request it as a parameter instead", field.Name, t)
+				}
+			}
+		}
+		in = append(in, t)
+	}
+
+	if len(in) > 0 {
+		capture := reflect.MakeFunc(
+			reflect.FuncOf(in, nil, ft.IsVariadic()),
+			func(args []reflect.Value) []reflect.Value {
+				f.args = args
+				return nil
+			},
+		).Interface()
+		f.parent = groupFunc(s.app.lazyFunc(capture))
+		f.parentNode = &graph.Node{Params: fxreflect.InspectSignature(capture).Params}
+	}
+	return f, nil
+}
+
+// call calls the function in the given scope and returns its results.
+// Callers must hold the mu of the scope.
+func (f *scopeFunc) call(s *Scope) ([]reflect.Value, error) {
+	var parentArgs []reflect.Value
+	if f.parent != nil {
+		app := s.scopes.app
+		app.containerMu.Lock()
+		err := app.container.Invoke(f.parent)
+		parentArgs, f.args = f.args, nil
+		app.containerMu.Unlock()
+		if err != nil {
+			return nil, app.containerError(f.parentNode, err)
+		}
+	}
+
+	args := make([]reflect.Value, len(f.Scoped))
+	for i, t := range f.Scoped {
+		if t == nil {
+			args[i], parentArgs = parentArgs[0], parentArgs[1:]
+			continue
+		}
+
+		v, err := s.value(t)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	if f.Func.Type().IsVariadic() {
+		return f.Func.CallSlice(args), nil
+	}
+	return f.Func.Call(args), nil
+}
+
+// resultError returns the error reported by the last of the given results
+// of a function, if any.
+func resultError(results []reflect.Value) error {
+	if n := len(results); n > 0 && results[n-1].Type() == _typeOfError {
+		if err, _ := results[n-1].Interface().(error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
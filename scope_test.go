@@ -0,0 +1,364 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestRequestScoped(t *testing.T) {
+	type ctxKey struct{}
+	type DB struct{}
+	type RequestLogger struct {
+		DB        *DB
+		RequestID string
+		Closed    bool
+	}
+
+	newRequestLogger := func(ctx context.Context, db *DB, lc fx.Lifecycle) *RequestLogger {
+		l := &RequestLogger{DB: db, RequestID: ctx.Value(ctxKey{}).(string)}
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				l.Closed = true
+				return nil
+			},
+		})
+		return l
+	}
+
+	t.Run("ValuesArePerScope", func(t *testing.T) {
+		var (
+			scopes *fx.Scopes
+			db     *DB
+			calls  int
+		)
+		app := fxtest.New(t,
+			fx.Provide(func() *DB { return &DB{} }),
+			fx.RequestScoped(func(ctx context.Context, db *DB, lc fx.Lifecycle) *RequestLogger {
+				calls++
+				return newRequestLogger(ctx, db, lc)
+			}),
+			fx.Populate(&scopes, &db),
+		)
+		defer app.RequireStart().RequireStop()
+
+		begin := func(id string) (*fx.Scope, *RequestLogger) {
+			ctx := context.WithValue(context.Background(), ctxKey{}, id)
+			scope, err := scopes.Begin(ctx)
+			require.NoError(t, err)
+
+			var l1, l2 *RequestLogger
+			require.NoError(t, scope.Invoke(func(l *RequestLogger) { l1 = l }))
+			require.NoError(t, scope.Invoke(func(l *RequestLogger) { l2 = l }))
+			assert.Same(t, l1, l2, "values must be built once per scope")
+			return scope, l1
+		}
+
+		scope1, l1 := begin("1")
+		scope2, l2 := begin("2")
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, "1", l1.RequestID)
+		assert.Equal(t, "2", l2.RequestID)
+		assert.Same(t, db, l1.DB)
+		assert.Same(t, db, l2.DB)
+
+		require.NoError(t, scope1.End(context.Background()))
+		assert.True(t, l1.Closed)
+		assert.False(t, l2.Closed)
+		require.NoError(t, scope2.End(context.Background()))
+		assert.True(t, l2.Closed)
+	})
+
+	t.Run("ScopedDependencies", func(t *testing.T) {
+		type Tx struct {
+			Log    *RequestLogger
+			Closed bool
+		}
+
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.Provide(func() *DB { return &DB{} }),
+			fx.RequestScoped(
+				// Tx is registered before the logger it depends on.
+				func(l *RequestLogger, lc fx.Lifecycle) *Tx {
+					tx := &Tx{Log: l}
+					lc.Append(fx.Hook{
+						OnStop: func(context.Context) error {
+							assert.False(t, l.Closed, "hooks must stop in reverse order")
+							tx.Closed = true
+							return nil
+						},
+					})
+					return tx
+				},
+				newRequestLogger,
+			),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.WithValue(context.Background(), ctxKey{}, "1"))
+		require.NoError(t, err)
+
+		var (
+			tx *Tx
+			l  *RequestLogger
+		)
+		require.NoError(t, scope.Invoke(func(t *Tx, rl *RequestLogger) { tx, l = t, rl }))
+		assert.Same(t, l, tx.Log)
+
+		require.NoError(t, scope.End(context.Background()))
+		assert.True(t, tx.Closed)
+		assert.True(t, l.Closed)
+	})
+
+	t.Run("ConstructorsAreCalledLazily", func(t *testing.T) {
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.RequestScoped(func() *DB {
+				t.Fatal("constructor must not be called")
+				return nil
+			}),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		defer scope.End(context.Background())
+
+		require.NoError(t, scope.Invoke(func(ctx context.Context) {}))
+	})
+
+	t.Run("InvokeErrors", func(t *testing.T) {
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.RequestScoped(func() (*RequestLogger, error) {
+				return nil, errors.New("great sadness")
+			}),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		defer scope.End(context.Background())
+
+		err = scope.Invoke(func(*RequestLogger) {})
+		require.Error(t, err)
+		var invokeErr *fx.InvokeError
+		require.True(t, errors.As(err, &invokeErr), "expected an InvokeError")
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Contains(t, err.Error(), "TestRequestScoped")
+
+		err = scope.Invoke(func(*DB) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing type: *fx_test.DB")
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+
+		err = scope.Invoke(func() error { return errors.New("invoke failed") })
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invoke failed")
+
+		err = scope.Invoke("not a function")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't invoke non-function")
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		type A struct{}
+		type B struct{}
+
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.RequestScoped(
+				func(*B) *A { return &A{} },
+				func(*A) *B { return &B{} },
+			),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		defer scope.End(context.Background())
+
+		err = scope.Invoke(func(*A) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cycle detected")
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		type Tx struct{ DB *DB }
+
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.Strict(),
+			fx.Provide(func() *DB { return &DB{} }),
+			fx.RequestScoped(func(db *DB) *Tx { return &Tx{DB: db} }),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+	})
+
+	t.Run("InvalidConstructors", func(t *testing.T) {
+		type out struct {
+			fx.Out
+
+			DB *DB
+		}
+
+		tests := []struct {
+			desc string
+			opt  fx.Option
+			want string
+		}{
+			{
+				desc: "not a function",
+				opt:  fx.RequestScoped(&DB{}),
+				want: "must provide constructor function",
+			},
+			{
+				desc: "annotated",
+				opt: fx.RequestScoped(fx.Annotated{
+					Name:   "db",
+					Target: func() *DB { return &DB{} },
+				}),
+				want: "cannot be annotated",
+			},
+			{
+				desc: "result object",
+				opt:  fx.RequestScoped(func() out { return out{} }),
+				want: "cannot return fx.Out structs",
+			},
+			{
+				desc: "no results",
+				opt:  fx.RequestScoped(func() error { return nil }),
+				want: "must produce at least one value",
+			},
+			{
+				desc: "already provided",
+				opt: fx.Options(
+					fx.Provide(func() *DB { return &DB{} }),
+					fx.RequestScoped(func() *DB { return &DB{} }),
+				),
+				want: "cannot provide *fx_test.DB: already provided",
+			},
+			{
+				desc: "provided by Fx",
+				opt:  fx.RequestScoped(func() context.Context { return context.Background() }),
+				want: "cannot provide context.Context: already provided",
+			},
+			{
+				desc: "scoped field",
+				opt: fx.RequestScoped(func(struct {
+					fx.In
+
+					Lifecycle fx.Lifecycle
+				}) *DB {
+					return &DB{}
+				}),
+				want: "field Lifecycle of struct",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := fx.New(fx.NopLogger, tt.opt)
+				err := app.Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "fx.RequestScoped(")
+				assert.Contains(t, err.Error(), tt.want)
+
+				var provideErr *fx.ProvideError
+				require.True(t, errors.As(err, &provideErr), "expected a ProvideError")
+				assert.Contains(t, provideErr.Stack.String(), "scope_test.go")
+			})
+		}
+	})
+
+	t.Run("InvokeAfterEnd", func(t *testing.T) {
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.RequestScoped(func() *DB { return &DB{} }),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, scope.End(context.Background()))
+		require.NoError(t, scope.End(context.Background()), "End must be idempotent")
+
+		assert.Error(t, scope.Invoke(func(*DB) {}))
+	})
+
+	t.Run("HookErrors", func(t *testing.T) {
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.RequestScoped(func(lc fx.Lifecycle) *DB {
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error { return errors.New("great sadness") },
+				})
+				return &DB{}
+			}),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		defer scope.End(context.Background())
+
+		err = scope.Invoke(func(*DB) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+
+	t.Run("StopHookErrors", func(t *testing.T) {
+		var scopes *fx.Scopes
+		app := fxtest.New(t,
+			fx.RequestScoped(func(lc fx.Lifecycle) *DB {
+				lc.Append(fx.Hook{
+					OnStop: func(context.Context) error { return errors.New("great sadness") },
+				})
+				return &DB{}
+			}),
+			fx.Populate(&scopes),
+		)
+		defer app.RequireStart().RequireStop()
+
+		scope, err := scopes.Begin(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, scope.Invoke(func(*DB) {}))
+
+		err = scope.End(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/internal/fxreflect"
+	"go.uber.org/fx/internal/graph"
+)
+
+// Strict fails the application if any of its constructors is never used.
+// Since constructors are only called if their results are needed,
+// constructors whose results are never requested by an invoked function,
+// directly or through other constructors, are silently ignored otherwise.
+//
+// Once all functions have been invoked, the application fails with an
+// error that lists each unused constructor, grouped by the function that
+// provided it. Fx also emits an fxevent.Unused event for each of them.
+//
+// Use ReportUnused to emit the events without failing the application.
+func Strict() Option {
+	return strictOption{Fail: true, Stack: fxreflect.CallerStack(1, 0)}
+}
+
+// ReportUnused emits an fxevent.Unused event for each constructor that is
+// never used, without failing the application. See Strict for details.
+func ReportUnused() Option {
+	return strictOption{Stack: fxreflect.CallerStack(1, 0)}
+}
+
+type strictOption struct {
+	Fail  bool
+	Stack fxreflect.Stack
+}
+
+func (o strictOption) apply(app *App) {
+	app.reportUnused = true
+	app.strict = app.strict || o.Fail
+}
+
+func (o strictOption) visit(v *optionVisitor) {
+	if o.Fail {
+		v.report(o, "fx.Strict", o.Stack)
+	} else {
+		v.report(o, "fx.ReportUnused", o.Stack)
+	}
+}
+
+func (o strictOption) String() string {
+	if o.Fail {
+		return "fx.Strict()"
+	}
+	return "fx.ReportUnused()"
+}
+
+// checkUnused reports constructors that were never used, returning an
+// error if the application is strict.
+func (app *App) checkUnused() error {
+	if !app.reportUnused {
+		return nil
+	}
+
+	unused := app.graph.Unused()
+	for _, n := range unused {
+		outputNames := make([]string, len(n.Results))
+		for i, k := range n.Results {
+			outputNames[i] = k.String()
+		}
+		app.log.LogEvent(&fxevent.Unused{
+			Constructor:     n.Func,
+			OutputTypeNames: outputNames,
+			Stacktrace:      fmt.Sprintf("%+v", n.Stack),
+		})
+	}
+
+	if !app.strict || len(unused) == 0 {
+		return nil
+	}
+	return newUnusedError(unused)
+}
+
+// newUnusedError builds an error listing the given constructors grouped by
+// the function that provided them, in the form,
+//
+//  fx.Strict: found 2 unused constructors:
+//  from go.uber.org/foo.Module:
+//  	go.uber.org/foo.NewA() (foo/module.go:12) provides *foo.A
+//  	go.uber.org/foo.NewB() (foo/module.go:13) provides *foo.B
+func newUnusedError(unused []*graph.Node) error {
+	var callers []string
+	byCaller := make(map[string][]*graph.Node)
+	for _, n := range unused {
+		caller := n.Stack.CallerName()
+		if _, ok := byCaller[caller]; !ok {
+			callers = append(callers, caller)
+		}
+		byCaller[caller] = append(byCaller[caller], n)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "fx.Strict: found %d unused constructors:", len(unused))
+	for _, caller := range callers {
+		fmt.Fprintf(&sb, "\nfrom %v:", caller)
+		for _, n := range byCaller[caller] {
+			results := make([]string, len(n.Results))
+			for i, k := range n.Results {
+				results[i] = k.String()
+			}
+			fmt.Fprintf(&sb, "\n\t%v%v provides %v",
+				fxreflect.FuncName(n.Func), location(n.Stack), strings.Join(results, ", "))
+		}
+	}
+	return errors.New(sb.String())
+}
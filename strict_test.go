@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/zap"
+)
+
+func TestStrict(t *testing.T) {
+	type A struct{}
+	type B struct{}
+	type C struct{}
+
+	newA := func() *A { return &A{} }
+	newB := func(*A) *B { return &B{} }
+	newC := func() *C { return &C{} }
+
+	t.Run("AllUsed", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Strict(),
+			fx.Provide(newA, newB),
+			fx.Invoke(func(*B) {}),
+		)
+		defer app.RequireStart().RequireStop()
+	})
+
+	t.Run("Unused", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fx.New(
+			fx.Strict(),
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Provide(newA, newB, newC),
+			fx.Supply(bytes.NewBuffer(nil)),
+			fx.Invoke(func(*A) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+
+		msg := err.Error()
+		assert.True(t, strings.HasPrefix(msg, "fx.Strict: found 3 unused constructors:\n"), msg)
+		assert.Regexp(t, `\nfrom go.uber.org/fx_test.TestStrict.func\d+:\n`, msg)
+		assert.Regexp(t, `\tgo.uber.org/fx_test.TestStrict.func2\(\) \(.+strict_test.go:\d+\) provides \*fx_test.B\n`, msg)
+		assert.Regexp(t, `\tgo.uber.org/fx_test.TestStrict.func3\(\) \(.+strict_test.go:\d+\) provides \*fx_test.C\n`, msg)
+		assert.Regexp(t, `\treflect.makeFuncStub\(\) \(.+strict_test.go:\d+\) provides \*bytes.Buffer$`, msg)
+
+		var unused []string
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Unused); ok {
+				unused = append(unused, e.OutputTypeNames...)
+			}
+		}
+		assert.Equal(t, []string{"*fx_test.B", "*fx_test.C", "*bytes.Buffer"}, unused)
+	})
+
+	t.Run("ReportUnused", func(t *testing.T) {
+		spy := new(fxlog.Spy)
+		app := fxtest.New(t,
+			fx.ReportUnused(),
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Provide(newA, newC),
+			fx.Invoke(func(*A) {}),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var unused []string
+		for _, ev := range spy.Events() {
+			if e, ok := ev.(*fxevent.Unused); ok {
+				unused = append(unused, e.OutputTypeNames...)
+			}
+		}
+		assert.Equal(t, []string{"*fx_test.C"}, unused)
+	})
+
+	t.Run("LoggerDependenciesAreUsed", func(t *testing.T) {
+		app := fx.New(
+			fx.Strict(),
+			fx.Provide(zap.NewNop),
+			fx.WithLogger(func(log *zap.Logger) fxevent.Logger {
+				return &fxevent.ZapLogger{Logger: log}
+			}),
+		)
+		require.NoError(t, app.Err())
+	})
+
+	t.Run("OptionalAndGroupsAreUsed", func(t *testing.T) {
+		app := fxtest.New(t,
+			fx.Strict(),
+			fx.Provide(
+				newA,
+				fx.Annotated{Group: "cs", Target: newC},
+			),
+			fx.Invoke(func(p struct {
+				fx.In
+
+				A  *A   `optional:"true"`
+				Cs []*C `group:"cs"`
+			}) {
+			}),
+		)
+		defer app.RequireStart().RequireStop()
+	})
+}
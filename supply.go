@@ -53,6 +53,11 @@ import (
 //  )
 //
 // Supply panics if a value (or annotation target) is an untyped nil or an error.
+//
+// An option returned by Supply may be included in an application more than
+// once, and its values are supplied only once. Values passed to separate
+// calls to Supply are never considered the same, so supplying two values of
+// the same type fails, even if they're equal.
 func Supply(values ...interface{}) Option {
 	constructors := make([]interface{}, len(values)) // one function per value
 	types := make([]reflect.Type, len(values))
@@ -60,17 +65,18 @@ func Supply(values ...interface{}) Option {
 		switch value := value.(type) {
 		case Annotated:
 			var typ reflect.Type
-			value.Target, typ = newSupplyConstructor(value.Target)
+			value.Target, typ = newValueConstructor("fx.Supply", value.Target)
 			constructors[i] = value
 			types[i] = typ
 		default:
-			constructors[i], types[i] = newSupplyConstructor(value)
+			constructors[i], types[i] = newValueConstructor("fx.Supply", value)
 		}
 	}
 
 	return supplyOption{
 		Targets: constructors,
 		Types:   types,
+		Values:  values,
 		Stack:   fxreflect.CallerStack(1, 0),
 	}
 }
@@ -78,6 +84,7 @@ func Supply(values ...interface{}) Option {
 type supplyOption struct {
 	Targets []interface{}
 	Types   []reflect.Type // type of value produced by constructor[i]
+	Values  []interface{}  // values passed to fx.Supply
 	Stack   fxreflect.Stack
 }
 
@@ -86,12 +93,18 @@ func (o supplyOption) apply(app *App) {
 		app.provides = append(app.provides, provide{
 			Target:     target,
 			Stack:      o.Stack,
+			Key:        newProvideKey(target),
+			Source:     app.source(o.Stack),
 			IsSupply:   true,
 			SupplyType: o.Types[i],
 		})
 	}
 }
 
+func (o supplyOption) visit(v *optionVisitor) {
+	v.report(o, "fx.Supply", o.Stack, o.Values...)
+}
+
 func (o supplyOption) String() string {
 	items := make([]string, 0, len(o.Targets))
 	for _, typ := range o.Types {
@@ -101,12 +114,13 @@ func (o supplyOption) String() string {
 }
 
 // Returns a function that takes no parameters, and returns the given value.
-func newSupplyConstructor(value interface{}) (interface{}, reflect.Type) {
+// opt is the name of the option the value was passed to.
+func newValueConstructor(opt string, value interface{}) (interface{}, reflect.Type) {
 	switch value.(type) {
 	case nil:
-		panic("untyped nil passed to fx.Supply")
+		panic("untyped nil passed to " + opt)
 	case error:
-		panic("error value passed to fx.Supply")
+		panic("error value passed to " + opt)
 	}
 
 	typ := reflect.TypeOf(value)
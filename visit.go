@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import "go.uber.org/fx/internal/fxreflect"
+
+// OptionInfo describes an option visited by VisitOptions.
+type OptionInfo struct {
+	// Option is the option being visited.
+	Option Option
+
+	// Kind is the name of the function that built the option, for example
+	// "fx.Provide" or "fx.Options".
+	Kind string
+
+	// Targets holds the arguments the option was built with. These are the
+	// constructors passed to fx.Provide, the functions passed to fx.Invoke,
+	// the values passed to fx.Supply and fx.Default, the pointers passed to
+	// fx.Populate, the errors passed to fx.Error, the durations passed to
+	// fx.StartTimeout and fx.StopTimeout, and the constructor passed to
+	// fx.WithLogger.
+	//
+	// For fx.If and fx.When, Targets holds the condition. Options that bundle
+	// other options, like fx.Options, have no targets.
+	Targets []interface{}
+
+	// Caller is the fully qualified name of the function that built the
+	// option, and File and Line are the location in that function where it
+	// was built. These are empty for fx.DebugHandler, which is a variable
+	// rather than built by a function.
+	Caller string
+	File   string
+	Line   int
+
+	// Path holds the options that enclose this option, outermost first. It
+	// is empty for the option passed to VisitOptions.
+	Path []Option
+}
+
+// VisitOptions walks the tree of options rooted at opt, calling visit for
+// opt and every option nested inside it in the order in which they would
+// be applied. Options that bundle other options, like fx.Options, are
+// visited before the options they contain.
+//
+// Options guarded by fx.If and fx.When are visited regardless of whether
+// their condition holds. Conditions are never evaluated.
+//
+// VisitOptions does not build an application. It's intended for tools
+// that inspect libraries of options, such as linters and documentation
+// generators.
+func VisitOptions(opt Option, visit func(OptionInfo)) {
+	opt.visit(&optionVisitor{fn: visit})
+}
+
+// optionVisitor tracks the position of VisitOptions in the option tree.
+type optionVisitor struct {
+	fn   func(OptionInfo)
+	path []Option
+}
+
+// report reports a single option with the given kind, targets, and the
+// stack it was built with.
+func (v *optionVisitor) report(opt Option, kind string, stack fxreflect.Stack, targets ...interface{}) {
+	info := OptionInfo{
+		Option:  opt,
+		Kind:    kind,
+		Targets: targets,
+		Path:    append([]Option(nil), v.path...),
+	}
+	if f, ok := stack.Caller(); ok {
+		info.Caller = f.Function
+		info.File = f.File
+		info.Line = f.Line
+	}
+	v.fn(info)
+}
+
+// nest visits options enclosed by parent.
+func (v *optionVisitor) nest(parent Option, opts ...Option) {
+	v.path = append(v.path, parent)
+	for _, opt := range opts {
+		opt.visit(v)
+	}
+	v.path = v.path[:len(v.path)-1]
+}
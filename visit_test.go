@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestVisitOptions(t *testing.T) {
+	t.Run("Kinds", func(t *testing.T) {
+		var buf *bytes.Buffer
+		err := errors.New("great sadness")
+		cond := func() bool { return true }
+
+		module := fx.Options(
+			fx.Provide(bytes.NewBufferString),
+			fx.Invoke(func(*bytes.Buffer) {}),
+			fx.Supply("hello"),
+			fx.Default(42),
+			fx.Populate(&buf),
+			fx.Error(err),
+			fx.StartTimeout(time.Second),
+			fx.StopTimeout(time.Minute),
+			fx.If(true, fx.NopLogger),
+			fx.When(cond),
+		)
+
+		var kinds []string
+		targets := make(map[string][]interface{})
+		fx.VisitOptions(module, func(info fx.OptionInfo) {
+			kinds = append(kinds, info.Kind)
+			targets[info.Kind] = info.Targets
+		})
+
+		assert.Equal(t, []string{
+			"fx.Options",
+			"fx.Provide",
+			"fx.Invoke",
+			"fx.Supply",
+			"fx.Default",
+			"fx.Populate",
+			"fx.Error",
+			"fx.StartTimeout",
+			"fx.StopTimeout",
+			"fx.If",
+			"fx.WithLogger",
+			"fx.When",
+		}, kinds)
+
+		assert.Empty(t, targets["fx.Options"])
+		assert.Len(t, targets["fx.Provide"], 1)
+		assert.Equal(t, []interface{}{"hello"}, targets["fx.Supply"])
+		assert.Equal(t, []interface{}{42}, targets["fx.Default"])
+		assert.Equal(t, []interface{}{&buf}, targets["fx.Populate"])
+		assert.Equal(t, []interface{}{err}, targets["fx.Error"])
+		assert.Equal(t, []interface{}{time.Second}, targets["fx.StartTimeout"])
+		assert.Equal(t, []interface{}{time.Minute}, targets["fx.StopTimeout"])
+		assert.Equal(t, []interface{}{true}, targets["fx.If"])
+		require.Len(t, targets["fx.When"], 1)
+	})
+
+	t.Run("Path", func(t *testing.T) {
+		provide := fx.Provide(bytes.NewBufferString)
+		inner := fx.Options(provide)
+		outer := fx.Options(inner)
+
+		paths := make(map[string][]fx.Option)
+		fx.VisitOptions(outer, func(info fx.OptionInfo) {
+			paths[info.Option.String()] = info.Path
+		})
+
+		assert.Empty(t, paths[outer.String()])
+		assert.Equal(t, []fx.Option{outer}, paths[inner.String()])
+		assert.Equal(t, []fx.Option{outer, inner}, paths[provide.String()])
+	})
+
+	t.Run("Caller", func(t *testing.T) {
+		opt := fx.Provide(bytes.NewBufferString)
+
+		var infos []fx.OptionInfo
+		fx.VisitOptions(opt, func(info fx.OptionInfo) {
+			infos = append(infos, info)
+		})
+
+		require.Len(t, infos, 1)
+		assert.Equal(t, "go.uber.org/fx_test.TestVisitOptions.func3", infos[0].Caller)
+		assert.Contains(t, infos[0].File, "visit_test.go")
+		assert.NotZero(t, infos[0].Line)
+	})
+
+	t.Run("CallerOfSettings", func(t *testing.T) {
+		opt := fx.Options(
+			fx.Eager(),
+			fx.Strict(),
+			fx.ReportUnused(),
+			fx.RecoverFromPanics(),
+			fx.AutoLifecycle(),
+		)
+
+		var infos []fx.OptionInfo
+		fx.VisitOptions(opt, func(info fx.OptionInfo) {
+			infos = append(infos, info)
+		})
+
+		require.Len(t, infos, 6)
+		for _, info := range infos[1:] {
+			assert.Equal(t, "go.uber.org/fx_test.TestVisitOptions.func4", info.Caller, info.Kind)
+			assert.Contains(t, info.File, "visit_test.go", info.Kind)
+			assert.NotZero(t, info.Line, info.Kind)
+		}
+	})
+
+	t.Run("DebugHandler", func(t *testing.T) {
+		var infos []fx.OptionInfo
+		fx.VisitOptions(fx.DebugHandler, func(info fx.OptionInfo) {
+			infos = append(infos, info)
+		})
+
+		require.Len(t, infos, 1)
+		assert.Equal(t, "fx.DebugHandler", infos[0].Kind)
+		assert.Empty(t, infos[0].Caller)
+		assert.Empty(t, infos[0].File)
+		assert.Zero(t, infos[0].Line)
+	})
+}